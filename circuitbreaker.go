@@ -2,27 +2,150 @@ package fastbreaker
 
 import (
 	"errors"
+	"fmt"
+	"time"
 )
 
 // ErrCircuitStopped is the error returned by FastCircuitBreaker.Allow() when the circuit is stopped.
 var ErrCircuitStopped = errors.New("circuit breaker is stopped")
 
-// ErrCircuitOpen is the error returned by FastCircuitBreaker.Allow() when the circuit is open.
+// ErrCircuitOpen is the error wrapped by the OpenError returned by FastCircuitBreaker.Allow() when the
+// circuit is open.
 var ErrCircuitOpen = errors.New("circuit breaker is open")
 
+// ErrCircuitForcedOpen is the error wrapped by the OpenError returned by FastCircuitBreaker.Allow()
+// when the circuit has been manually opened with ForceOpen.
+var ErrCircuitForcedOpen = errors.New("circuit breaker is forced open")
+
+// ErrCircuitIsolated is the error wrapped by the OpenError returned by FastCircuitBreaker.Allow()
+// when the circuit has been manually isolated with Isolate.
+var ErrCircuitIsolated = errors.New("circuit breaker is isolated")
+
+// ErrCallTimeout is the error returned by Execute when the execution does not complete within
+// Configuration.CallTimeout. It is also the cause of the cancellation of the execution context.
+var ErrCallTimeout = errors.New("circuit breaker call timed out")
+
+// OpenError is the error returned by FastCircuitBreaker.Allow() and FastCircuitBreaker.Acquire() when
+// the circuit rejects the execution because it is open, half-open without permits available, forced
+// open or isolated. It wraps ErrCircuitOpen, ErrCircuitForcedOpen or ErrCircuitIsolated depending on
+// its State, so it can be checked with errors.Is.
+// The same OpenError is returned for every execution rejected between two state changes, so rejecting
+// does not allocate. It must not be modified.
+type OpenError struct {
+	// Name is the Configuration.Name of the circuit breaker.
+	Name string
+	// State is the state of the circuit when the execution was rejected.
+	State State
+	// OpenedAt is the time the circuit last opened, was forced open or was isolated.
+	OpenedAt time.Time
+	// RetryAt is the time the circuit is expected to allow executions again, either because it becomes
+	// half-open or because its override expires. It is the zero time when it is unknown, like when the
+	// circuit is pinned without ttl or it is half-open without permits available.
+	RetryAt time.Time
+	// clock is the Configuration.Clock of the circuit breaker, used to compute RetryAfter.
+	clock Clock
+}
+
+func (e *OpenError) Error() string {
+	message := e.Unwrap().Error()
+	if e.Name != "" {
+		message = fmt.Sprintf("%s: %s", e.Name, message)
+	}
+	if retryAfter := e.RetryAfter(); retryAfter > 0 {
+		message = fmt.Sprintf("%s, retry after %s", message, retryAfter)
+	}
+	return message
+}
+
+// RetryAfter returns the estimated time until the circuit allows executions again, or 0 if RetryAt is
+// unknown or already passed.
+func (e *OpenError) RetryAfter() time.Duration {
+	if e.RetryAt.IsZero() {
+		return 0
+	}
+	var now time.Time
+	if e.clock != nil {
+		now = e.clock.Now()
+	} else {
+		now = time.Now()
+	}
+	if retryAfter := e.RetryAt.Sub(now); retryAfter > 0 {
+		return retryAfter
+	}
+	return 0
+}
+
+// Unwrap returns the sentinel error matching the State of the error.
+func (e *OpenError) Unwrap() error {
+	switch e.State {
+	case StateForcedOpen:
+		return ErrCircuitForcedOpen
+	case StateIsolated:
+		return ErrCircuitIsolated
+	default:
+		return ErrCircuitOpen
+	}
+}
+
 // FastBreaker is the interface implemented by the circuit breakers.
 type FastBreaker interface {
 	// Configuration returns the actual configuration used to create the circuit breaker.
 	Configuration() Configuration
 
-	// Stop releases the circuit breaker resources.
+	// Start starts a stopped circuit breaker in the closed state. It does nothing if the circuit
+	// breaker is already started.
+	Start()
+
+	// Stop releases the circuit breaker resources. It waits for the background goroutine of the
+	// circuit breaker to exit. It does nothing if the circuit breaker is already stopped.
 	Stop()
 
+	// Restart stops the circuit breaker if it is started and starts it again in the closed state.
+	Restart()
+
+	// ForceOpen pins the circuit in the StateForcedOpen state, rejecting every execution with an
+	// *OpenError wrapping ErrCircuitForcedOpen. If ttl is positive, the circuit becomes half-open
+	// after ttl.
+	// Returns ErrCircuitStopped if the circuit breaker is stopped.
+	ForceOpen(ttl time.Duration) error
+
+	// ForceClose pins the circuit in the StateForcedClosed state, allowing every execution without
+	// ever tripping. If ttl is positive, the circuit becomes closed after ttl.
+	// Returns ErrCircuitStopped if the circuit breaker is stopped.
+	ForceClose(ttl time.Duration) error
+
+	// Isolate pins the circuit in the StateIsolated state, rejecting every execution with an
+	// *OpenError wrapping ErrCircuitIsolated. If ttl is positive, the circuit becomes closed after
+	// ttl.
+	// Returns ErrCircuitStopped if the circuit breaker is stopped.
+	Isolate(ttl time.Duration) error
+
+	// ClearOverride closes a circuit pinned by ForceOpen, ForceClose or Isolate. It does nothing if the
+	// circuit is not pinned.
+	// Returns ErrCircuitStopped if the circuit breaker is stopped.
+	ClearOverride() error
+
+	// Subscribe calls listener with every state change of the circuit breaker. The listener is called
+	// in order from a dedicated goroutine; up to DefaultListenerBufferSize changes are buffered and
+	// further changes are dropped while the listener is busy.
+	// Returns the function to unsubscribe the listener.
+	Subscribe(listener func(StateChange)) func()
+
+	// Notify sends every state change of the circuit breaker to ch. Changes are dropped when ch is full.
+	// Returns the function to stop sending changes to ch. The channel is not closed.
+	Notify(ch chan<- StateChange) func()
+
 	// Allow checks if the circuit breaker should allow the execution to proceed.
 	// Returns a function to report if the execution was successful when the execution is allowed or an
-	// error when it is not.
+	// error when it is not: ErrCircuitStopped if the circuit breaker is stopped or an *OpenError
+	// otherwise.
 	Allow() (func(bool), error)
 
+	// Acquire checks if the circuit breaker should allow the execution to proceed.
+	// Returns a Permit to report the outcome of the execution when the execution is allowed or an
+	// error when it is not. Unlike Allow, Acquire does not allocate when the circuit is closed.
+	Acquire() (Permit, error)
+
 	// State returns the current State of the circuit breaker.
 	State() State
 
@@ -35,6 +158,41 @@ type FastBreaker interface {
 	// Rejected returns the number of executions the circuit breaker has rejected.
 	Rejected() uint64
 
+	// Ignored returns the number of executions reported with the OutcomeIgnored outcome. Ignored
+	// executions are not counted in Executions.
+	Ignored() uint64
+
+	// FallbackCounters returns the number of fallbacks called by ExecuteWithFallback that succeeded
+	// and failed.
+	FallbackCounters() (uint64, uint64)
+
+	// RecordFallback counts a fallback called instead of an execution rejected or failed by the circuit
+	// breaker, as succeeded or failed. ExecuteWithFallback calls it for every fallback, so wrappers of a
+	// FastBreaker should delegate it to keep FallbackCounters accurate.
+	RecordFallback(success bool)
+
+	// Timeouts returns the number of executions reported with the OutcomeTimeout outcome. Timeouts are
+	// counted in Failures too.
+	Timeouts() uint64
+
+	// SlowCalls returns the number of executions that took at least Configuration.SlowCallDuration.
+	SlowCalls() uint64
+
+	// ConsecutiveFailures returns the number of executions failed in a row, including the probes of
+	// the half-open state.
+	ConsecutiveFailures() uint64
+
+	// ConsecutiveSuccesses returns the number of executions succeeded in a row, including the probes
+	// of the half-open state. It is reset every time the circuit becomes half-open, so the circuit
+	// closes after Configuration.HalfOpenSuccessThreshold probes succeed in a row.
+	ConsecutiveSuccesses() uint64
+
 	// RollingCounters returns the rolling executions and failures.
 	RollingCounters() (uint64, uint64)
+
+	// RollingSlowCalls returns the rolling slow calls.
+	RollingSlowCalls() uint64
+
+	// Stats returns a snapshot of the counters of the circuit breaker.
+	Stats() Stats
 }
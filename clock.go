@@ -0,0 +1,239 @@
+package fastbreaker
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time used by a circuit breaker. It drives the rotation of the rolling
+// window buckets and the timers that move the circuit out of the open state.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker calls f every d until the returned Ticker is stopped.
+	NewTicker(d time.Duration, f func()) Ticker
+
+	// AfterFunc waits for d to elapse and then calls f.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker is a periodic event created by Clock.NewTicker.
+type Ticker interface {
+	// Stop turns off the ticker. No more calls to the ticker function will be made after Stop returns.
+	Stop()
+}
+
+// Timer is a single event created by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the Timer from firing. It returns true if the call stops the timer, false if
+	// the timer has already expired or been stopped.
+	Stop() bool
+}
+
+// RealClock is the Clock backed by the time package. It is the default Clock of a circuit breaker.
+type RealClock struct{}
+
+// Now implements Clock
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker implements Clock
+func (RealClock) NewTicker(d time.Duration, f func()) Ticker {
+	ticker := &realTicker{
+		ticker: time.NewTicker(d),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go ticker.run(f)
+	return ticker
+}
+
+// AfterFunc implements Clock
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+	done   chan struct{}
+	exited chan struct{}
+	once   sync.Once
+}
+
+func (t *realTicker) run(f func()) {
+	defer close(t.exited)
+	for {
+		select {
+		case <-t.ticker.C:
+			f()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *realTicker) Stop() {
+	t.once.Do(func() {
+		t.ticker.Stop()
+		close(t.done)
+	})
+	// Wait for the ticker goroutine to exit.
+	<-t.exited
+}
+
+// FakeClock is a Clock that only moves when it is told to. It is meant to be used in tests to
+// drive a circuit breaker deterministically.
+// Tickers and timers created by a FakeClock are fired synchronously by Advance, in the goroutine
+// calling Advance.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	events []*fakeEvent
+}
+
+// NewFakeClock returns a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTicker implements Clock
+func (c *FakeClock) NewTicker(d time.Duration, f func()) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	return fakeTicker{c.schedule(d, d, f)}
+}
+
+// AfterFunc implements Clock
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, 0, f)
+}
+
+// Advance moves the clock forward by d, firing in order every ticker and timer that expires
+// in the meantime.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	for {
+		event := c.nextEvent(target)
+		if event == nil {
+			break
+		}
+		c.now = event.deadline
+		if event.period > 0 {
+			event.deadline = event.deadline.Add(event.period)
+		} else {
+			c.remove(event)
+		}
+		c.mutex.Unlock()
+		event.f()
+		c.mutex.Lock()
+	}
+	c.now = target
+	c.mutex.Unlock()
+}
+
+func (c *FakeClock) schedule(d time.Duration, period time.Duration, f func()) *fakeEvent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	event := &fakeEvent{
+		clock:    c,
+		deadline: c.now.Add(d),
+		period:   period,
+		f:        f,
+	}
+	c.events = append(c.events, event)
+	return event
+}
+
+// nextEvent returns the first event expiring not later than target.
+func (c *FakeClock) nextEvent(target time.Time) *fakeEvent {
+	sort.SliceStable(c.events, func(i, j int) bool {
+		return c.events[i].deadline.Before(c.events[j].deadline)
+	})
+	if len(c.events) == 0 || c.events[0].deadline.After(target) {
+		return nil
+	}
+	return c.events[0]
+}
+
+// remove removes the event from the scheduled events. It returns false if the event was not scheduled.
+func (c *FakeClock) remove(event *fakeEvent) bool {
+	for i, e := range c.events {
+		if e == event {
+			c.events = append(c.events[:i], c.events[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeEvent struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration
+	f        func()
+}
+
+func (e *fakeEvent) Stop() bool {
+	e.clock.mutex.Lock()
+	defer e.clock.mutex.Unlock()
+	return e.clock.remove(e)
+}
+
+type fakeTicker struct {
+	event *fakeEvent
+}
+
+func (t fakeTicker) Stop() {
+	t.event.Stop()
+}
+
+// timerSlot holds at most one armed timer. Arming a new timer or clearing the slot cancels the
+// armed one, so the function of a replaced timer is never called even if it was already firing.
+type timerSlot struct {
+	armed atomic.Pointer[armedTimer]
+}
+
+type armedTimer struct {
+	// timer holds the Timer returned by Clock.AfterFunc.
+	timer atomic.Value
+}
+
+func (t *armedTimer) stop() {
+	if timer, ok := t.timer.Load().(Timer); ok {
+		timer.Stop()
+	}
+}
+
+// arm replaces the armed timer by a new one calling f after d.
+func (s *timerSlot) arm(clock Clock, d time.Duration, f func()) {
+	armed := &armedTimer{}
+	if previous := s.armed.Swap(armed); previous != nil {
+		previous.stop()
+	}
+	armed.timer.Store(clock.AfterFunc(d, func() {
+		// Only the timer still armed in the slot calls f.
+		if s.armed.CompareAndSwap(armed, nil) {
+			f()
+		}
+	}))
+}
+
+// clear cancels the armed timer.
+func (s *timerSlot) clear() {
+	if previous := s.armed.Swap(nil); previous != nil {
+		previous.stop()
+	}
+}
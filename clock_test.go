@@ -0,0 +1,70 @@
+package fastbreaker_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+
+	var fired []string
+	clock.AfterFunc(3*time.Second, func() { fired = append(fired, "timer") })
+	stopped := clock.AfterFunc(2*time.Second, func() { fired = append(fired, "stopped") })
+	ticker := clock.NewTicker(1*time.Second, func() { fired = append(fired, "tick "+clock.Now().Sub(start).String()) })
+
+	if !stopped.Stop() {
+		t.Fatal("stopping a pending timer should return true.")
+	}
+	if stopped.Stop() {
+		t.Fatal("stopping a stopped timer should return false.")
+	}
+
+	clock.Advance(2500 * time.Millisecond)
+	assertFired(t, fired, "tick 1s", "tick 2s")
+
+	clock.Advance(1 * time.Second)
+	assertFired(t, fired, "tick 1s", "tick 2s", "tick 3s", "timer")
+
+	ticker.Stop()
+	clock.Advance(10 * time.Second)
+	assertFired(t, fired, "tick 1s", "tick 2s", "tick 3s", "timer")
+
+	if elapsed := clock.Now().Sub(start); elapsed != 13500*time.Millisecond {
+		t.Fatalf("expected 13.5s to elapse but got %s.", elapsed)
+	}
+}
+
+func assertFired(t *testing.T, actual []string, expected ...string) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("expected events %q but got %q.", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected events %q but got %q.", expected, actual)
+		}
+	}
+}
+
+func TestRealClockTickerStop(t *testing.T) {
+	var ticks atomic.Int32
+	ticker := fastbreaker.RealClock{}.NewTicker(time.Millisecond, func() { ticks.Add(1) })
+	for ticks.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// No more ticks should happen after Stop returns.
+	ticker.Stop()
+	ticker.Stop()
+	stoppedTicks := ticks.Load()
+	time.Sleep(10 * time.Millisecond)
+	if ticks.Load() != stoppedTicks {
+		t.Fatalf("expected %d ticks but got %d.", stoppedTicks, ticks.Load())
+	}
+}
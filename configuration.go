@@ -1,6 +1,10 @@
 package fastbreaker
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 const (
 	// DefaultNumBuckets is the default number of buckets of a circuit breaker. Value = 10.
@@ -9,23 +13,141 @@ const (
 	DefaultBucketDuration = 1 * time.Second
 	// DefaultDurationOfBreak is the default duration of a circuit breaker break. Value = 5s
 	DefaultDurationOfBreak = 5 * time.Second
+	// DefaultWindowSize is the default number of executions of a count based window. Value = 100.
+	DefaultWindowSize = 100
+	// DefaultHalfOpenMaxProbes is the default number of concurrent executions allowed in the half-open state. Value = 1.
+	DefaultHalfOpenMaxProbes = 1
+	// DefaultHalfOpenSuccessThreshold is the default number of successful executions required to close the circuit
+	// from the half-open state. Value = 1.
+	DefaultHalfOpenSuccessThreshold = 1
+	// DefaultHalfOpenProbeTimeout is the default time the circuit breaker waits for the feedback of an execution
+	// allowed in the half-open state. Value = 5s.
+	DefaultHalfOpenProbeTimeout = 5 * time.Second
+	// DefaultBreakBackoffMultiplier is the default factor applied to the duration of a break every time the
+	// circuit opens again from the half-open state. Value = 1 (no backoff).
+	DefaultBreakBackoffMultiplier = 1.0
+	// DefaultMaxDurationOfBreak is the default maximum duration of a break. Value = 1m.
+	DefaultMaxDurationOfBreak = 1 * time.Minute
+	// DefaultCounterStripes is the default number of stripes of the execution counters. Value = 1.
+	DefaultCounterStripes = 1
+
+	// MinBucketDuration is the minimum duration of a bucket. Value = 10ms.
+	MinBucketDuration = 10 * time.Millisecond
+	// MinDurationOfBreak is the minimum duration of a circuit breaker break. Value = 10ms.
+	MinDurationOfBreak = 10 * time.Millisecond
 )
 
+// ErrInvalidConfiguration is the error returned by Configuration.Validate() when the configuration
+// can not be used to build a circuit breaker.
+var ErrInvalidConfiguration = errors.New("invalid circuit breaker configuration")
+
 // DefaultShouldTrip is the default implementation of the ShouldTrip function.
-// If will trip the circuit when there has been at least 20 executions and at least 50% of the
-// executions failed.
+// If will trip the circuit when the DefaultTripPolicy does: when there has been at least
+// DefaultMinExecutions executions and at least DefaultFailureRateThreshold of the executions failed.
 func DefaultShouldTrip(executions uint64, failures uint64) bool {
-	return executions >= 20 && failures*2 >= executions
+	return DefaultTripPolicy.ShouldTrip(Stats{Executions: executions, Failures: failures})
 }
 
+// WindowType is the kind of rolling window used by a circuit breaker.
+type WindowType uint32
+
+const (
+	// TimeBasedWindow is a rolling window holding the executions of the last NumBuckets buckets of
+	// BucketDuration.
+	TimeBasedWindow WindowType = iota
+	// CountBasedWindow is a rolling window holding the last WindowSize executions.
+	CountBasedWindow
+	// LazyTimeBasedWindow is a rolling window holding the executions of the last NumBuckets buckets
+	// of BucketDuration. Unlike TimeBasedWindow, the buckets are rotated when the window is accessed,
+	// so the circuit breaker does not need a background goroutine.
+	LazyTimeBasedWindow
+)
+
 // Configuration is a struct used to configure a circuit breaker.
 type Configuration struct {
-	NumBuckets      int
-	BucketDuration  time.Duration
-	DurationOfBreak time.Duration
-	ShouldTrip      ShouldTripFunc
+	Name                     string
+	WindowType               WindowType
+	NumBuckets               int
+	BucketDuration           time.Duration
+	WindowSize               int
+	DurationOfBreak          time.Duration
+	HalfOpenMaxProbes        int
+	HalfOpenSuccessThreshold int
+	HalfOpenProbeTimeout     time.Duration
+	BreakBackoffMultiplier   float64
+	MaxDurationOfBreak       time.Duration
+	BreakJitter              float64
+	ShouldTrip               ShouldTripFunc
+	SlowCallDuration         time.Duration
+	ShouldTripSlow           ShouldTripFunc
+	TripPolicy               TripPolicy
+	Classifier               ClassifierFunc
+	CallTimeout              time.Duration
+	Clock                    Clock
+	CounterStripes           int
 }
 
 // A ShouldTripFunc tells the circuit breaker to trip when it returns true. If it returns false,
 // the circuit breaker will remain closed.
 type ShouldTripFunc func(executions uint64, failures uint64) bool
+
+// ShouldTrip implements TripPolicy calling f with the rolling executions and failures.
+func (f ShouldTripFunc) ShouldTrip(stats Stats) bool {
+	return f(stats.Executions, stats.Failures)
+}
+
+// A TripPolicy decides when the circuit breaker trips. ShouldTrip is called with the Stats of the
+// circuit breaker every time an execution fails or is slow in the closed state. If it returns true,
+// the circuit breaker trips. If it returns false, the circuit breaker will remain closed.
+type TripPolicy interface {
+	ShouldTrip(stats Stats) bool
+}
+
+// shouldTripPolicy is the TripPolicy of a configuration without a TripPolicy. It trips when either
+// ShouldTrip, with the executions and failures, or ShouldTripSlow, with the executions and slow calls,
+// returns true.
+type shouldTripPolicy struct {
+	shouldTrip     ShouldTripFunc
+	shouldTripSlow ShouldTripFunc
+}
+
+func (policy shouldTripPolicy) ShouldTrip(stats Stats) bool {
+	return policy.shouldTrip(stats.Executions, stats.Failures) ||
+		policy.shouldTripSlow(stats.Executions, stats.SlowCalls)
+}
+
+// Validate checks that the configuration can be used to build a circuit breaker.
+// Zero or negative values are valid as they are replaced by their defaults, but a positive
+// BucketDuration or DurationOfBreak must not be lower than MinBucketDuration and MinDurationOfBreak,
+// a positive MaxDurationOfBreak must not be lower than DurationOfBreak and BreakJitter must not be
+// greater than 1. WindowType must be one of the known window types.
+// Validate returns an error wrapping ErrInvalidConfiguration when the configuration is not valid.
+func (configuration Configuration) Validate() error {
+	switch configuration.WindowType {
+	case TimeBasedWindow, CountBasedWindow, LazyTimeBasedWindow:
+	default:
+		return fmt.Errorf("%w: unknown WindowType %d", ErrInvalidConfiguration, configuration.WindowType)
+	}
+
+	if configuration.BucketDuration > 0 && configuration.BucketDuration < MinBucketDuration {
+		return fmt.Errorf("%w: BucketDuration %s is lower than %s", ErrInvalidConfiguration, configuration.BucketDuration, MinBucketDuration)
+	}
+
+	if configuration.DurationOfBreak > 0 && configuration.DurationOfBreak < MinDurationOfBreak {
+		return fmt.Errorf("%w: DurationOfBreak %s is lower than %s", ErrInvalidConfiguration, configuration.DurationOfBreak, MinDurationOfBreak)
+	}
+
+	durationOfBreak := configuration.DurationOfBreak
+	if durationOfBreak <= 0 {
+		durationOfBreak = DefaultDurationOfBreak
+	}
+	if configuration.MaxDurationOfBreak > 0 && configuration.MaxDurationOfBreak < durationOfBreak {
+		return fmt.Errorf("%w: MaxDurationOfBreak %s is lower than DurationOfBreak %s", ErrInvalidConfiguration, configuration.MaxDurationOfBreak, durationOfBreak)
+	}
+
+	if configuration.BreakJitter > 1 {
+		return fmt.Errorf("%w: BreakJitter %g is greater than 1", ErrInvalidConfiguration, configuration.BreakJitter)
+	}
+
+	return nil
+}
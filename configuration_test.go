@@ -1,6 +1,7 @@
 package fastbreaker_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -33,6 +34,62 @@ func TestConfigurationNumBuckets(t *testing.T) {
 	}
 }
 
+func TestConfigurationHalfOpen(t *testing.T) {
+	type testSpec struct {
+		name            string
+		args            int
+		expectProbes    int
+		expectThreshold int
+	}
+
+	tests := []testSpec{
+		{"-1", -1, fastbreaker.DefaultHalfOpenMaxProbes, fastbreaker.DefaultHalfOpenSuccessThreshold},
+		{"0", 0, fastbreaker.DefaultHalfOpenMaxProbes, fastbreaker.DefaultHalfOpenSuccessThreshold},
+		{"1", 1, 1, 1},
+		{"5", 5, 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{HalfOpenMaxProbes: tt.args, HalfOpenSuccessThreshold: tt.args})
+			configuration := cb.Configuration()
+			if configuration.HalfOpenMaxProbes != tt.expectProbes {
+				t.Errorf("expected %d half-open probes but got %d", tt.expectProbes, configuration.HalfOpenMaxProbes)
+			}
+			if configuration.HalfOpenSuccessThreshold != tt.expectThreshold {
+				t.Errorf("expected %d half-open success threshold but got %d", tt.expectThreshold, configuration.HalfOpenSuccessThreshold)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationWindowSize(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   int
+		expect int
+	}
+
+	tests := []testSpec{
+		{"-1", -1, fastbreaker.DefaultWindowSize},
+		{"0", 0, fastbreaker.DefaultWindowSize},
+		{"1", 1, 1},
+		{"50", 50, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{WindowType: fastbreaker.CountBasedWindow, WindowSize: tt.args})
+			configuration := cb.Configuration()
+			if configuration.WindowSize != tt.expect {
+				t.Errorf("expected window size %d but got %d", tt.expect, configuration.WindowSize)
+			}
+			cb.Stop()
+		})
+	}
+}
+
 func TestConfigurationBucketDuration(t *testing.T) {
 	type testSpec struct {
 		name   string
@@ -43,10 +100,12 @@ func TestConfigurationBucketDuration(t *testing.T) {
 	tests := []testSpec{
 		{"-1s", -1 * time.Second, fastbreaker.DefaultBucketDuration},
 		{"0s", 0 * time.Second, fastbreaker.DefaultBucketDuration},
-		{"0.5s", 500 * time.Millisecond, fastbreaker.DefaultBucketDuration},
-		{"1.5s", 1500 * time.Millisecond, 1 * time.Second},
+		{"min", fastbreaker.MinBucketDuration, fastbreaker.MinBucketDuration},
+		{"100ms", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"0.5s", 500 * time.Millisecond, 500 * time.Millisecond},
+		{"1.5s", 1500 * time.Millisecond, 1500 * time.Millisecond},
 		{"2s", 2 * time.Second, 2 * time.Second},
-		{"2.5s", 2500 * time.Millisecond, 2 * time.Second},
+		{"2.5s", 2500 * time.Millisecond, 2500 * time.Millisecond},
 	}
 
 	for _, tt := range tests {
@@ -71,10 +130,12 @@ func TestConfigurationDurationOfBreak(t *testing.T) {
 	tests := []testSpec{
 		{"-1s", -1 * time.Second, fastbreaker.DefaultDurationOfBreak},
 		{"0s", 0 * time.Second, fastbreaker.DefaultDurationOfBreak},
-		{"0.5s", 500 * time.Millisecond, fastbreaker.DefaultDurationOfBreak},
-		{"1.5s", 1500 * time.Millisecond, 1 * time.Second},
+		{"min", fastbreaker.MinDurationOfBreak, fastbreaker.MinDurationOfBreak},
+		{"100ms", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"0.5s", 500 * time.Millisecond, 500 * time.Millisecond},
+		{"1.5s", 1500 * time.Millisecond, 1500 * time.Millisecond},
 		{"2s", 2 * time.Second, 2 * time.Second},
-		{"2.5s", 2500 * time.Millisecond, 2 * time.Second},
+		{"2.5s", 2500 * time.Millisecond, 2500 * time.Millisecond},
 	}
 
 	for _, tt := range tests {
@@ -89,6 +150,66 @@ func TestConfigurationDurationOfBreak(t *testing.T) {
 	}
 }
 
+func TestConfigurationHalfOpenProbeTimeout(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   time.Duration
+		expect time.Duration
+	}
+
+	tests := []testSpec{
+		{"-1s", -1 * time.Second, fastbreaker.DefaultHalfOpenProbeTimeout},
+		{"0s", 0 * time.Second, fastbreaker.DefaultHalfOpenProbeTimeout},
+		{"100ms", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"2s", 2 * time.Second, 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{HalfOpenProbeTimeout: tt.args})
+			configuration := cb.Configuration()
+			if configuration.HalfOpenProbeTimeout != tt.expect {
+				t.Errorf("expected %d timeout but got %d", tt.expect, configuration.HalfOpenProbeTimeout)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationBreakBackoff(t *testing.T) {
+	type testSpec struct {
+		name             string
+		args             fastbreaker.Configuration
+		expectMultiplier float64
+		expectMax        time.Duration
+		expectJitter     float64
+	}
+
+	tests := []testSpec{
+		{"defaults", fastbreaker.Configuration{}, fastbreaker.DefaultBreakBackoffMultiplier, fastbreaker.DefaultMaxDurationOfBreak, 0},
+		{"negative", fastbreaker.Configuration{BreakBackoffMultiplier: -1, MaxDurationOfBreak: -1, BreakJitter: -1}, fastbreaker.DefaultBreakBackoffMultiplier, fastbreaker.DefaultMaxDurationOfBreak, 0},
+		{"long break", fastbreaker.Configuration{DurationOfBreak: 2 * fastbreaker.DefaultMaxDurationOfBreak}, fastbreaker.DefaultBreakBackoffMultiplier, 2 * fastbreaker.DefaultMaxDurationOfBreak, 0},
+		{"custom", fastbreaker.Configuration{BreakBackoffMultiplier: 1.5, MaxDurationOfBreak: 30 * time.Second, BreakJitter: 0.2}, 1.5, 30 * time.Second, 0.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(tt.args)
+			configuration := cb.Configuration()
+			if configuration.BreakBackoffMultiplier != tt.expectMultiplier {
+				t.Errorf("expected %g multiplier but got %g", tt.expectMultiplier, configuration.BreakBackoffMultiplier)
+			}
+			if configuration.MaxDurationOfBreak != tt.expectMax {
+				t.Errorf("expected %d max duration of break but got %d", tt.expectMax, configuration.MaxDurationOfBreak)
+			}
+			if configuration.BreakJitter != tt.expectJitter {
+				t.Errorf("expected %g jitter but got %g", tt.expectJitter, configuration.BreakJitter)
+			}
+			cb.Stop()
+		})
+	}
+}
+
 func TestConfigurationShouldTrip(t *testing.T) {
 	var customShouldTripCalled bool
 	customShouldTrip := func(executions uint64, failures uint64) bool {
@@ -123,3 +244,207 @@ func TestConfigurationShouldTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigurationTripPolicy(t *testing.T) {
+	neverTrip := fastbreaker.ShouldTripFunc(func(executions uint64, failures uint64) bool { return false })
+
+	type testSpec struct {
+		name   string
+		args   fastbreaker.TripPolicy
+		expect bool
+	}
+
+	tests := []testSpec{
+		{"nil", nil, true},
+		{"notnil", neverTrip, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{TripPolicy: tt.args})
+			configuration := cb.Configuration()
+			if configuration.TripPolicy == nil {
+				t.Fatalf("TripPolicy should not be nil")
+			}
+
+			actual := configuration.TripPolicy.ShouldTrip(fastbreaker.Stats{Executions: 20, Failures: 10})
+			if actual != tt.expect {
+				t.Errorf("expected ShouldTrip %t but was %t", tt.expect, actual)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationClassifier(t *testing.T) {
+	ignoreAll := func(err error) fastbreaker.Outcome { return fastbreaker.OutcomeIgnored }
+
+	type testSpec struct {
+		name   string
+		args   fastbreaker.ClassifierFunc
+		expect fastbreaker.Outcome
+	}
+
+	tests := []testSpec{
+		{"nil", nil, fastbreaker.OutcomeFailure},
+		{"notnil", ignoreAll, fastbreaker.OutcomeIgnored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{Classifier: tt.args})
+			configuration := cb.Configuration()
+			if configuration.Classifier == nil {
+				t.Fatalf("Classifier should not be nil")
+			}
+
+			if actual := configuration.Classifier(errors.New("boom")); actual != tt.expect {
+				t.Errorf("expected %s but got %s", tt.expect, actual)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationSlowCalls(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   time.Duration
+		expect time.Duration
+	}
+
+	tests := []testSpec{
+		{"-1s", -1 * time.Second, 0},
+		{"0s", 0, 0},
+		{"100ms", 100 * time.Millisecond, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{SlowCallDuration: tt.args})
+			configuration := cb.Configuration()
+			if configuration.SlowCallDuration != tt.expect {
+				t.Errorf("expected %d slow call duration but got %d", tt.expect, configuration.SlowCallDuration)
+			}
+			if configuration.ShouldTripSlow == nil {
+				t.Errorf("ShouldTripSlow should not be nil")
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationCounterStripes(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   int
+		expect int
+	}
+
+	tests := []testSpec{
+		{"-1", -1, fastbreaker.DefaultCounterStripes},
+		{"0", 0, fastbreaker.DefaultCounterStripes},
+		{"1", 1, 1},
+		{"16", 16, 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{CounterStripes: tt.args})
+			configuration := cb.Configuration()
+			if configuration.CounterStripes != tt.expect {
+				t.Errorf("expected %d counter stripes but got %d", tt.expect, configuration.CounterStripes)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationCallTimeout(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   time.Duration
+		expect time.Duration
+	}
+
+	tests := []testSpec{
+		{"-1s", -1 * time.Second, 0},
+		{"0s", 0, 0},
+		{"100ms", 100 * time.Millisecond, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{CallTimeout: tt.args})
+			configuration := cb.Configuration()
+			if configuration.CallTimeout != tt.expect {
+				t.Errorf("expected %d call timeout but got %d", tt.expect, configuration.CallTimeout)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationClock(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   fastbreaker.Clock
+		expect fastbreaker.Clock
+	}
+
+	fakeClock := newFakeClock()
+
+	tests := []testSpec{
+		{"nil", nil, fastbreaker.RealClock{}},
+		{"fake", fakeClock, fakeClock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := fastbreaker.New(fastbreaker.Configuration{Clock: tt.args})
+			configuration := cb.Configuration()
+			if configuration.Clock != tt.expect {
+				t.Errorf("expected clock %T but got %T", tt.expect, configuration.Clock)
+			}
+			cb.Stop()
+		})
+	}
+}
+
+func TestConfigurationValidate(t *testing.T) {
+	type testSpec struct {
+		name   string
+		args   fastbreaker.Configuration
+		expect error
+	}
+
+	tests := []testSpec{
+		{"defaults", fastbreaker.Configuration{}, nil},
+		{"count window", fastbreaker.Configuration{WindowType: fastbreaker.CountBasedWindow}, nil},
+		{"lazy time window", fastbreaker.Configuration{WindowType: fastbreaker.LazyTimeBasedWindow}, nil},
+		{"window type", fastbreaker.Configuration{WindowType: fastbreaker.LazyTimeBasedWindow + 1}, fastbreaker.ErrInvalidConfiguration},
+		{"min", fastbreaker.Configuration{BucketDuration: fastbreaker.MinBucketDuration, DurationOfBreak: fastbreaker.MinDurationOfBreak}, nil},
+		{"bucket", fastbreaker.Configuration{BucketDuration: fastbreaker.MinBucketDuration - 1}, fastbreaker.ErrInvalidConfiguration},
+		{"break", fastbreaker.Configuration{DurationOfBreak: fastbreaker.MinDurationOfBreak - 1}, fastbreaker.ErrInvalidConfiguration},
+		{"max break", fastbreaker.Configuration{MaxDurationOfBreak: fastbreaker.DefaultDurationOfBreak - 1}, fastbreaker.ErrInvalidConfiguration},
+		{"max custom break", fastbreaker.Configuration{DurationOfBreak: time.Second, MaxDurationOfBreak: time.Second}, nil},
+		{"jitter", fastbreaker.Configuration{BreakJitter: 1.1}, fastbreaker.ErrInvalidConfiguration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.args.Validate()
+			if !errors.Is(err, tt.expect) {
+				t.Fatalf("expected error %v but got %v", tt.expect, err)
+			}
+
+			defer func() {
+				recovered := recover()
+				if (recovered != nil) != (tt.expect != nil) {
+					t.Errorf("New should panic only with invalid configurations, but recovered %v", recovered)
+				}
+			}()
+			fastbreaker.New(tt.args).Stop()
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package fastbreaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultListenerBufferSize is the number of state changes buffered for every listener registered
+// with Subscribe. Value = 64.
+const DefaultListenerBufferSize = 64
+
+// TransitionReason is the cause of a state change.
+type TransitionReason uint32
+
+func (reason TransitionReason) String() string {
+	switch reason {
+	case ReasonStarted:
+		return "started"
+	case ReasonStopped:
+		return "stopped"
+	case ReasonTripped:
+		return "tripped"
+	case ReasonBreakElapsed:
+		return "break-elapsed"
+	case ReasonProbeSucceeded:
+		return "probe-succeeded"
+	case ReasonProbeFailed:
+		return "probe-failed"
+	case ReasonProbeTimedOut:
+		return "probe-timed-out"
+	case ReasonOverridden:
+		return "overridden"
+	case ReasonOverrideExpired:
+		return "override-expired"
+	case ReasonOverrideCleared:
+		return "override-cleared"
+	default:
+		return fmt.Sprintf("unknown reason %d", reason)
+	}
+}
+
+const (
+	// ReasonStarted is the reason of the transition from the stopped state by Start or Restart.
+	ReasonStarted TransitionReason = iota
+	// ReasonStopped is the reason of the transition to the stopped state by Stop or Restart.
+	ReasonStopped
+	// ReasonTripped is the reason of the transition from the closed state to the open state.
+	ReasonTripped
+	// ReasonBreakElapsed is the reason of the transition from the open state to the half-open state.
+	ReasonBreakElapsed
+	// ReasonProbeSucceeded is the reason of the transition from the half-open state to the closed state.
+	ReasonProbeSucceeded
+	// ReasonProbeFailed is the reason of the transition from the half-open state to the open state
+	// when an execution fails.
+	ReasonProbeFailed
+	// ReasonProbeTimedOut is the reason of the transition from the half-open state to the open state
+	// when an execution is not reported within HalfOpenProbeTimeout.
+	ReasonProbeTimedOut
+	// ReasonOverridden is the reason of the transition by ForceOpen, ForceClose or Isolate.
+	ReasonOverridden
+	// ReasonOverrideExpired is the reason of the transition when the ttl of an override elapses.
+	ReasonOverrideExpired
+	// ReasonOverrideCleared is the reason of the transition by ClearOverride.
+	ReasonOverrideCleared
+)
+
+// StateChange is the event published every time a circuit breaker changes its state.
+type StateChange struct {
+	// From is the state before the change.
+	From State
+	// To is the state after the change.
+	To State
+	// Reason is the cause of the change.
+	Reason TransitionReason
+	// Time is the time of the change, as reported by the circuit breaker Clock.
+	Time time.Time
+	// Executions is the number of executions in the rolling window at the time of the change.
+	Executions uint64
+	// Failures is the number of failures in the rolling window at the time of the change.
+	Failures uint64
+	// SlowCalls is the number of slow calls in the rolling window at the time of the change.
+	SlowCalls uint64
+}
+
+// subscribers holds the channels state changes are published to.
+type subscribers struct {
+	mutex    sync.RWMutex
+	channels map[*subscription]struct{}
+}
+
+type subscription struct {
+	ch chan<- StateChange
+	// owned is true when the channel was created by Subscribe and must be closed on unsubscription.
+	owned bool
+}
+
+// add registers the channel and returns the function to unregister it.
+func (s *subscribers) add(ch chan<- StateChange, owned bool) func() {
+	sub := &subscription{ch: ch, owned: owned}
+
+	s.mutex.Lock()
+	if s.channels == nil {
+		s.channels = make(map[*subscription]struct{})
+	}
+	s.channels[sub] = struct{}{}
+	s.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mutex.Lock()
+			delete(s.channels, sub)
+			s.mutex.Unlock()
+			if sub.owned {
+				close(sub.ch)
+			}
+		})
+	}
+}
+
+// empty returns true when there are no channels registered.
+func (s *subscribers) empty() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.channels) == 0
+}
+
+// publish sends the change to every registered channel without blocking. The change is dropped
+// for the channels that are full.
+func (s *subscribers) publish(change StateChange) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for sub := range s.channels {
+		select {
+		case sub.ch <- change:
+		default:
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package fastbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestNotify(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		DurationOfBreak:      time.Second,
+		HalfOpenProbeTimeout: time.Second,
+		Clock:                clock,
+	})
+	changes := make(chan fastbreaker.StateChange, 100)
+	unsubscribe := cb.Notify(changes)
+	start := clock.Now()
+	durationOfBreak := cb.Configuration().DurationOfBreak
+
+	// Trip the circuit and wait for the break to elapse.
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	assertStateChange(t, changes, fastbreaker.StateClosed, fastbreaker.StateOpen, fastbreaker.ReasonTripped, start, 20, 20)
+	clock.Advance(durationOfBreak)
+	assertStateChange(t, changes, fastbreaker.StateOpen, fastbreaker.StateHalfOpen, fastbreaker.ReasonBreakElapsed, start.Add(durationOfBreak), 20, 20)
+
+	// Fail, time out and succeed a probe.
+	allowAndAssert(t, cb, true)(false)
+	assertStateChange(t, changes, fastbreaker.StateHalfOpen, fastbreaker.StateOpen, fastbreaker.ReasonProbeFailed, start.Add(durationOfBreak), 20, 20)
+	clock.Advance(durationOfBreak)
+	assertStateChange(t, changes, fastbreaker.StateOpen, fastbreaker.StateHalfOpen, fastbreaker.ReasonBreakElapsed, start.Add(2*durationOfBreak), 20, 20)
+	allowAndAssert(t, cb, true)
+	clock.Advance(cb.Configuration().HalfOpenProbeTimeout)
+	assertStateChange(t, changes, fastbreaker.StateHalfOpen, fastbreaker.StateOpen, fastbreaker.ReasonProbeTimedOut, start.Add(2*durationOfBreak+cb.Configuration().HalfOpenProbeTimeout), 20, 20)
+	clock.Advance(durationOfBreak)
+	<-changes
+	allowAndAssert(t, cb, true)(true)
+	now := clock.Now()
+	assertStateChange(t, changes, fastbreaker.StateHalfOpen, fastbreaker.StateClosed, fastbreaker.ReasonProbeSucceeded, now, 20, 20)
+
+	// Override the circuit.
+	cb.Isolate(time.Second)
+	assertStateChange(t, changes, fastbreaker.StateClosed, fastbreaker.StateIsolated, fastbreaker.ReasonOverridden, now, 0, 0)
+	clock.Advance(time.Second)
+	assertStateChange(t, changes, fastbreaker.StateIsolated, fastbreaker.StateClosed, fastbreaker.ReasonOverrideExpired, now.Add(time.Second), 0, 0)
+	cb.ForceOpen(0)
+	assertStateChange(t, changes, fastbreaker.StateClosed, fastbreaker.StateForcedOpen, fastbreaker.ReasonOverridden, now.Add(time.Second), 0, 0)
+	cb.ClearOverride()
+	assertStateChange(t, changes, fastbreaker.StateForcedOpen, fastbreaker.StateClosed, fastbreaker.ReasonOverrideCleared, now.Add(time.Second), 0, 0)
+
+	// Restart the circuit breaker.
+	cb.Restart()
+	assertStateChange(t, changes, fastbreaker.StateClosed, fastbreaker.StateStopped, fastbreaker.ReasonStopped, now.Add(time.Second), 0, 0)
+	assertStateChange(t, changes, fastbreaker.StateStopped, fastbreaker.StateClosed, fastbreaker.ReasonStarted, now.Add(time.Second), 0, 0)
+
+	// No more changes should be sent after unsubscribing.
+	unsubscribe()
+	unsubscribe()
+	cb.Stop()
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected state change %+v.", change)
+	default:
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	changes := make(chan fastbreaker.StateChange)
+	unsubscribe := cb.Subscribe(func(change fastbreaker.StateChange) {
+		changes <- change
+	})
+	defer unsubscribe()
+
+	// The listener should be called in order.
+	cb.ForceOpen(0)
+	cb.ForceClose(0)
+	cb.ClearOverride()
+	assertStateChange(t, changes, fastbreaker.StateClosed, fastbreaker.StateForcedOpen, fastbreaker.ReasonOverridden, clock.Now(), 0, 0)
+	assertStateChange(t, changes, fastbreaker.StateForcedOpen, fastbreaker.StateForcedClosed, fastbreaker.ReasonOverridden, clock.Now(), 0, 0)
+	assertStateChange(t, changes, fastbreaker.StateForcedClosed, fastbreaker.StateClosed, fastbreaker.ReasonOverrideCleared, clock.Now(), 0, 0)
+}
+
+func TestTransitionReasonString(t *testing.T) {
+	tests := map[fastbreaker.TransitionReason]string{
+		fastbreaker.ReasonStarted:         "started",
+		fastbreaker.ReasonStopped:         "stopped",
+		fastbreaker.ReasonTripped:         "tripped",
+		fastbreaker.ReasonBreakElapsed:    "break-elapsed",
+		fastbreaker.ReasonProbeSucceeded:  "probe-succeeded",
+		fastbreaker.ReasonProbeFailed:     "probe-failed",
+		fastbreaker.ReasonProbeTimedOut:   "probe-timed-out",
+		fastbreaker.ReasonOverridden:      "overridden",
+		fastbreaker.ReasonOverrideExpired: "override-expired",
+		fastbreaker.ReasonOverrideCleared: "override-cleared",
+		fastbreaker.TransitionReason(100): "unknown reason 100",
+	}
+
+	for reason, expected := range tests {
+		if reason.String() != expected {
+			t.Errorf("expected %q but got %q.", expected, reason.String())
+		}
+	}
+}
+
+func assertStateChange(t *testing.T, changes <-chan fastbreaker.StateChange, from fastbreaker.State, to fastbreaker.State, reason fastbreaker.TransitionReason, at time.Time, executions uint64, failures uint64) {
+	t.Helper()
+
+	var change fastbreaker.StateChange
+	select {
+	case change = <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a state change from %s to %s.", from, to)
+	}
+
+	if change.From != from || change.To != to || change.Reason != reason {
+		t.Fatalf("expected a state change from %s to %s by %s but got from %s to %s by %s.", from, to, reason, change.From, change.To, change.Reason)
+	}
+	if !change.Time.Equal(at) {
+		t.Fatalf("expected a state change at %s but got %s.", at, change.Time)
+	}
+	if change.Executions != executions || change.Failures != failures {
+		t.Fatalf("expected %d executions and %d failures but got %d and %d.", executions, failures, change.Executions, change.Failures)
+	}
+}
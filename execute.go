@@ -0,0 +1,163 @@
+package fastbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// FallbackReason is the cause of calling the fallback of ExecuteWithFallback. The reasons are bit
+// flags, so they can be combined to select when the fallback is called.
+type FallbackReason uint32
+
+const (
+	// FallbackOnOpen is the reason of calling the fallback when the execution is rejected because the
+	// circuit is open, forced open, isolated or half-open without permits available.
+	FallbackOnOpen FallbackReason = 1 << iota
+	// FallbackOnStopped is the reason of calling the fallback when the execution is rejected because
+	// the circuit breaker is stopped.
+	FallbackOnStopped
+	// FallbackOnFailure is the reason of calling the fallback when the execution failed.
+	FallbackOnFailure
+	// FallbackOnTimeout is the reason of calling the fallback when the execution timed out, either
+	// because of Configuration.CallTimeout or because its deadline was exceeded.
+	FallbackOnTimeout
+
+	// FallbackOnRejection selects calling the fallback when the execution is rejected.
+	FallbackOnRejection = FallbackOnOpen | FallbackOnStopped
+	// FallbackOnAny selects calling the fallback when the execution is rejected or fails.
+	FallbackOnAny = FallbackOnRejection | FallbackOnFailure | FallbackOnTimeout
+)
+
+func (reason FallbackReason) String() string {
+	switch reason {
+	case FallbackOnOpen:
+		return "open"
+	case FallbackOnStopped:
+		return "stopped"
+	case FallbackOnFailure:
+		return "failed"
+	case FallbackOnTimeout:
+		return "timed-out"
+	default:
+		return fmt.Sprintf("unknown fallback reason %d", reason)
+	}
+}
+
+// A FallbackFunc provides the result of an execution that was rejected or failed. It is called with
+// the reason and the error of the execution.
+type FallbackFunc[T any] func(ctx context.Context, reason FallbackReason, err error) (T, error)
+
+// Execute runs fn if the circuit breaker allows it and reports the outcome of the execution from the
+// error returned by fn, as classified by Configuration.Classifier.
+// Execute returns the error of ctx without calling fn if ctx is done before the execution starts, and
+// the error returned by FastBreaker.Acquire if the execution is not allowed.
+// If Configuration.CallTimeout is positive and fn does not return within CallTimeout, the execution is
+// reported as timed out right away and the context passed to fn is done. With RealClock, the context
+// has a deadline CallTimeout after the execution starts, so fn can forward it, and its error is
+// context.DeadlineExceeded. Other clocks can not drive a deadline, so the context has no deadline and
+// is cancelled with ErrCallTimeout as its cause. If fn then returns an error, Execute returns an error
+// wrapping both ErrCallTimeout and the error of fn.
+// If fn panics, the execution is reported as failed and the panic is propagated.
+func Execute[T any](ctx context.Context, cb FastBreaker, fn func(context.Context) (T, error)) (T, error) {
+	result, _, err := execute(ctx, cb, fn)
+	return result, err
+}
+
+// ExecuteWithFallback runs fn like Execute. When the execution is rejected or fails for one of the
+// reasons selected by on, ExecuteWithFallback returns the result of calling fallback with the reason
+// and the error of the execution instead. Ignored executions, like the ones cancelled by the caller,
+// never call the fallback.
+// Every fallback is reported to FastBreaker.RecordFallback, as successful when it returns a nil error
+// and as failed otherwise.
+func ExecuteWithFallback[T any](ctx context.Context, cb FastBreaker, fn func(context.Context) (T, error), fallback FallbackFunc[T], on FallbackReason) (T, error) {
+	result, reason, err := execute(ctx, cb, fn)
+	if reason&on == 0 {
+		return result, err
+	}
+
+	result, err = fallback(ctx, reason, err)
+	cb.RecordFallback(err == nil)
+	return result, err
+}
+
+// execute runs fn like Execute. It also returns the reason to call a fallback, or 0 if the execution
+// succeeded or was ignored.
+func execute[T any](ctx context.Context, cb FastBreaker, fn func(context.Context) (T, error)) (T, FallbackReason, error) {
+	var zero T
+
+	// Do not start executions already cancelled by the caller.
+	if err := ctx.Err(); err != nil {
+		return zero, 0, err
+	}
+
+	permit, err := cb.Acquire()
+	if err != nil {
+		if errors.Is(err, ErrCircuitStopped) {
+			return zero, FallbackOnStopped, err
+		}
+		return zero, FallbackOnOpen, err
+	}
+
+	configuration := cb.Configuration()
+	if configuration.Classifier == nil {
+		configuration.Classifier = DefaultClassifier
+	}
+
+	// The outcome is reported once, either by the execution or by its timeout.
+	var reported atomic.Bool
+	report := func(outcome Outcome) {
+		if reported.CompareAndSwap(false, true) {
+			permit.Report(outcome)
+		}
+	}
+
+	// Report a failure if fn panics. The panic keeps unwinding the stack.
+	defer report(OutcomeFailure)
+
+	// timedOut tells if the context passed to fn was done because of CallTimeout.
+	timedOut := func() bool { return false }
+	if configuration.CallTimeout > 0 && configuration.Clock != nil {
+		var cancelCause context.CancelCauseFunc
+		if _, ok := configuration.Clock.(RealClock); ok {
+			// Expose the timeout as a deadline, so fn can forward it.
+			parent := ctx
+			callCtx, cancel := context.WithTimeout(ctx, configuration.CallTimeout)
+			defer cancel()
+			timedOut = func() bool { return callCtx.Err() == context.DeadlineExceeded && parent.Err() == nil }
+			ctx = callCtx
+		} else {
+			// Other clocks can not drive a deadline, so the timer cancels the context instead.
+			var callCtx context.Context
+			callCtx, cancelCause = context.WithCancelCause(ctx)
+			defer cancelCause(nil)
+			timedOut = func() bool { return errors.Is(context.Cause(callCtx), ErrCallTimeout) }
+			ctx = callCtx
+		}
+		timer := configuration.Clock.AfterFunc(configuration.CallTimeout, func() {
+			// Release the permit without waiting for fn to return.
+			report(OutcomeTimeout)
+			if cancelCause != nil {
+				cancelCause(ErrCallTimeout)
+			}
+		})
+		defer timer.Stop()
+	}
+
+	result, err := fn(ctx)
+	if err != nil && timedOut() {
+		err = fmt.Errorf("%w: %w", ErrCallTimeout, err)
+	}
+
+	outcome := configuration.Classifier(err)
+	report(outcome)
+	switch outcome {
+	case OutcomeFailure:
+		return result, FallbackOnFailure, err
+	case OutcomeTimeout:
+		return result, FallbackOnTimeout, err
+	default:
+		return result, 0, err
+	}
+}
@@ -0,0 +1,334 @@
+package fastbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestExecute(t *testing.T) {
+	errExecution := errors.New("execution failed")
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	// The result and the error of the execution should be returned and reported.
+	result, err := fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if result != 42 || err != nil {
+		t.Fatalf("expected 42 and no error but got %d and %v.", result, err)
+	}
+	result, err = fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (int, error) {
+		return 0, errExecution
+	})
+	if err != errExecution {
+		t.Fatalf("expected %v but got %v.", errExecution, err)
+	}
+	_, err = fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (int, error) {
+		return 0, context.Canceled
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected %v but got %v.", context.Canceled, err)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 2, 1)
+	if cb.Ignored() != 1 {
+		t.Fatalf("1 ignored execution expected but got %d instead.", cb.Ignored())
+	}
+
+	// A cancelled context should not start the execution.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fastbreaker.Execute(ctx, cb, func(ctx context.Context) (int, error) {
+		t.Fatal("the execution should not start.")
+		return 0, nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected %v but got %v.", context.Canceled, err)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 2, 1)
+
+	// Rejected executions should return the error of the circuit breaker.
+	cb.ForceOpen(0)
+	_, err = fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (int, error) {
+		t.Fatal("the execution should not start.")
+		return 0, nil
+	})
+	if !errors.Is(err, fastbreaker.ErrCircuitForcedOpen) {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrCircuitForcedOpen, err)
+	}
+}
+
+func TestExecutePanic(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	// A panic should be reported as a failure and propagated.
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be propagated but got %v.", r)
+			}
+		}()
+		fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (struct{}, error) {
+			panic("boom")
+		})
+	}()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 1, 1)
+
+	// A panicking probe should open the circuit again.
+	tripAndWaitHalfOpen(t, cb, clock)
+	func() {
+		defer func() { recover() }()
+		fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (struct{}, error) {
+			panic("boom")
+		})
+	}()
+	if cb.State() != fastbreaker.StateOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateOpen, cb.State())
+	}
+}
+
+func TestExecuteWithFallback(t *testing.T) {
+	errExecution := errors.New("execution failed")
+	errFallback := errors.New("fallback failed")
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	var actualReason fastbreaker.FallbackReason
+	var actualErr error
+	fallback := func(ctx context.Context, reason fastbreaker.FallbackReason, err error) (string, error) {
+		actualReason, actualErr = reason, err
+		if reason == fastbreaker.FallbackOnStopped {
+			return "", errFallback
+		}
+		return "fallback", nil
+	}
+	execute := func(err error, on fastbreaker.FallbackReason) (string, error) {
+		actualReason, actualErr = 0, nil
+		return fastbreaker.ExecuteWithFallback(context.Background(), cb, func(ctx context.Context) (string, error) {
+			if err != nil {
+				return "", err
+			}
+			return "execution", nil
+		}, fallback, on)
+	}
+	assertFallback := func(result string, err error, expectedResult string, expectedErr error, expectedReason fastbreaker.FallbackReason, expectedFallbackErr error) {
+		t.Helper()
+		if result != expectedResult || err != expectedErr {
+			t.Fatalf("expected %q and %v but got %q and %v.", expectedResult, expectedErr, result, err)
+		}
+		if actualReason != expectedReason || !errors.Is(actualErr, expectedFallbackErr) {
+			t.Fatalf("expected fallback for %s and %v but got %s and %v.", expectedReason, expectedFallbackErr, actualReason, actualErr)
+		}
+	}
+
+	// Successful and ignored executions should not call the fallback.
+	result, err := execute(nil, fastbreaker.FallbackOnAny)
+	assertFallback(result, err, "execution", nil, 0, nil)
+	result, err = execute(context.Canceled, fastbreaker.FallbackOnAny)
+	assertFallback(result, err, "", context.Canceled, 0, nil)
+
+	// Failed executions should only call the fallback when selected.
+	result, err = execute(errExecution, fastbreaker.FallbackOnRejection)
+	assertFallback(result, err, "", errExecution, 0, nil)
+	result, err = execute(errExecution, fastbreaker.FallbackOnFailure)
+	assertFallback(result, err, "fallback", nil, fastbreaker.FallbackOnFailure, errExecution)
+	result, err = execute(context.DeadlineExceeded, fastbreaker.FallbackOnFailure)
+	assertFallback(result, err, "", context.DeadlineExceeded, 0, nil)
+	result, err = execute(context.DeadlineExceeded, fastbreaker.FallbackOnTimeout)
+	assertFallback(result, err, "fallback", nil, fastbreaker.FallbackOnTimeout, context.DeadlineExceeded)
+
+	// Rejected executions should call the fallback with the reason of the rejection.
+	cb.Isolate(0)
+	result, err = execute(nil, fastbreaker.FallbackOnRejection)
+	assertFallback(result, err, "fallback", nil, fastbreaker.FallbackOnOpen, fastbreaker.ErrCircuitIsolated)
+	cb.Stop()
+	result, err = execute(nil, fastbreaker.FallbackOnRejection)
+	assertFallback(result, err, "", errFallback, fastbreaker.FallbackOnStopped, fastbreaker.ErrCircuitStopped)
+
+	// The fallbacks should be counted by their outcome.
+	successes, failures := cb.FallbackCounters()
+	if successes != 3 || failures != 1 {
+		t.Fatalf("expected 3 successful and 1 failed fallbacks but got %d and %d.", successes, failures)
+	}
+}
+
+// recordingBreaker wraps a FastBreaker counting the fallbacks reported to it.
+type recordingBreaker struct {
+	fastbreaker.FastBreaker
+	fallbacks int
+}
+
+func (cb *recordingBreaker) RecordFallback(success bool) {
+	cb.fallbacks++
+	cb.FastBreaker.RecordFallback(success)
+}
+
+func TestExecuteWithFallbackWrapper(t *testing.T) {
+	cb := &recordingBreaker{FastBreaker: fastbreaker.New(fastbreaker.Configuration{Clock: newFakeClock()})}
+	defer cb.Stop()
+
+	// The fallbacks should be reported to the wrapper, which delegates them to the circuit breaker.
+	cb.ForceOpen(0)
+	fastbreaker.ExecuteWithFallback(context.Background(), cb, func(ctx context.Context) (string, error) {
+		return "execution", nil
+	}, func(ctx context.Context, reason fastbreaker.FallbackReason, err error) (string, error) {
+		return "fallback", nil
+	}, fastbreaker.FallbackOnAny)
+	successes, failures := cb.FallbackCounters()
+	if cb.fallbacks != 1 || successes != 1 || failures != 0 {
+		t.Fatalf("expected 1 recorded and 1 successful fallback but got %d, %d and %d.", cb.fallbacks, successes, failures)
+	}
+}
+
+func TestFallbackReasonString(t *testing.T) {
+	tests := map[fastbreaker.FallbackReason]string{
+		fastbreaker.FallbackOnOpen:      "open",
+		fastbreaker.FallbackOnStopped:   "stopped",
+		fastbreaker.FallbackOnFailure:   "failed",
+		fastbreaker.FallbackOnTimeout:   "timed-out",
+		fastbreaker.FallbackReason(100): "unknown fallback reason 100",
+	}
+
+	for reason, expected := range tests {
+		if reason.String() != expected {
+			t.Errorf("expected %q but got %q.", expected, reason.String())
+		}
+	}
+}
+
+func TestExecuteCallTimeout(t *testing.T) {
+	const callTimeout = time.Second
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		// keep every timeout in the rolling window.
+		BucketDuration: time.Minute,
+		CallTimeout:    callTimeout,
+		Clock:          clock,
+	})
+	defer cb.Stop()
+
+	// hang runs fn after advancing the clock by CallTimeout while fn waits for its context.
+	hang := func(fn func(ctx context.Context) (string, error)) (string, error) {
+		started := make(chan struct{})
+		go func() {
+			<-started
+			clock.Advance(callTimeout)
+		}()
+		return fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (string, error) {
+			close(started)
+			<-ctx.Done()
+			if cause := context.Cause(ctx); cause != fastbreaker.ErrCallTimeout {
+				t.Errorf("expected the context to be cancelled by %v but got %v.", fastbreaker.ErrCallTimeout, cause)
+			}
+			// The timeout should be reported before the execution returns.
+			if cb.Timeouts() == 0 {
+				t.Error("expected the timeout to be reported.")
+			}
+			return fn(ctx)
+		})
+	}
+
+	// Executions returning within CallTimeout should not time out.
+	result, err := fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (string, error) {
+		clock.Advance(callTimeout - time.Millisecond)
+		return "execution", nil
+	})
+	if result != "execution" || err != nil {
+		t.Fatalf("expected %q and no error but got %q and %v.", "execution", result, err)
+	}
+	clock.Advance(callTimeout)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 1, 0)
+
+	// Hung executions should be reported as timeouts and return ErrCallTimeout.
+	_, err = hang(func(ctx context.Context) (string, error) {
+		return "", ctx.Err()
+	})
+	if !errors.Is(err, fastbreaker.ErrCallTimeout) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v wrapping %v but got %v.", fastbreaker.ErrCallTimeout, context.Canceled, err)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 2, 1)
+
+	// Executions succeeding after the timeout should return their result but count as timeouts.
+	result, err = hang(func(ctx context.Context) (string, error) {
+		return "late", nil
+	})
+	if result != "late" || err != nil {
+		t.Fatalf("expected %q and no error but got %q and %v.", "late", result, err)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 3, 2)
+	if cb.Timeouts() != 2 || cb.Stats().Timeouts != 2 {
+		t.Fatalf("expected 2 timeouts but got %d and %d rolling.", cb.Timeouts(), cb.Stats().Timeouts)
+	}
+
+	// Timeouts should trip the circuit like failures.
+	for cb.State() == fastbreaker.StateClosed {
+		hang(func(ctx context.Context) (string, error) {
+			return "", ctx.Err()
+		})
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 20, 19)
+
+	// A timed out probe should open the circuit again.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	hang(func(ctx context.Context) (string, error) {
+		return "", ctx.Err()
+	})
+	if cb.State() != fastbreaker.StateOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateOpen, cb.State())
+	}
+
+	// A timed out execution should call the fallback with the timeout reason.
+	cb.ForceClose(0)
+	started := make(chan struct{})
+	go func() {
+		<-started
+		clock.Advance(callTimeout)
+	}()
+	result, err = fastbreaker.ExecuteWithFallback(context.Background(), cb, func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, func(ctx context.Context, reason fastbreaker.FallbackReason, err error) (string, error) {
+		if !errors.Is(err, fastbreaker.ErrCallTimeout) {
+			t.Errorf("expected %v but got %v.", fastbreaker.ErrCallTimeout, err)
+		}
+		return reason.String(), nil
+	}, fastbreaker.FallbackOnTimeout)
+	if result != "timed-out" || err != nil {
+		t.Fatalf("expected %q and no error but got %q and %v.", "timed-out", result, err)
+	}
+}
+
+func TestExecuteCallTimeoutDeadline(t *testing.T) {
+	const callTimeout = 10 * time.Millisecond
+
+	cb := fastbreaker.New(fastbreaker.Configuration{CallTimeout: callTimeout})
+	defer cb.Stop()
+
+	// With the real clock, the timeout should be a deadline of the context passed to the execution.
+	_, err := fastbreaker.Execute(context.Background(), cb, func(ctx context.Context) (struct{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected the context to have a deadline.")
+		}
+		<-ctx.Done()
+		return struct{}{}, ctx.Err()
+	})
+	if !errors.Is(err, fastbreaker.ErrCallTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v wrapping %v but got %v.", fastbreaker.ErrCallTimeout, context.DeadlineExceeded, err)
+	}
+	// The timer of the real clock may still be reporting the timeout.
+	for cb.Timeouts() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 1, 1)
+}
@@ -1,75 +1,168 @@
 package fastbreaker
 
 import (
-	"container/ring"
+	"math"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-type counters struct {
-	executions atomic.Uint64
-	failures   atomic.Uint64
-}
-
-func (c *counters) reset() {
-	c.executions.Store(0)
-	c.failures.Store(0)
-}
-
 type fastBreaker struct {
-	configuration   Configuration
-	state           atomic.Value
-	ring            *ring.Ring
-	advanceTicker   *time.Ticker
-	totalCounters   *counters
-	rejected        atomic.Uint64
-	breakTimer      *time.Timer
-	halfOpenAllowed atomic.Bool
+	// lifecycle serializes Start, Stop and Restart.
+	lifecycle     sync.Mutex
+	configuration Configuration
+	state         atomicState
+	window        window
+	advanceTicker Ticker
+	totalCounters stripedCounters
+	rejected      atomic.Uint64
+	ignored       atomic.Uint64
+	// fallbackSuccesses and fallbackFailures count the fallbacks called by ExecuteWithFallback.
+	fallbackSuccesses atomic.Uint64
+	fallbackFailures  atomic.Uint64
+	breakTimer        timerSlot
+	halfOpenPermits   atomic.Int32
+	// halfOpenGeneration is incremented every time the circuit becomes half-open.
+	halfOpenGeneration atomic.Uint64
+	// halfOpenProbes holds the executions allowed in the half-open state waiting for their outcome.
+	halfOpenProbes halfOpenProbes
+	// trips is the number of times the circuit opened since it was closed.
+	trips atomic.Uint32
+	// lastTrip is the time the circuit last opened in nanoseconds since the Unix epoch, or 0.
+	lastTrip atomic.Int64
+	// openedAt is the time the circuit last opened, was forced open or was isolated in nanoseconds
+	// since the Unix epoch, or 0.
+	openedAt atomic.Int64
+	// retryAt is the time the circuit is expected to allow executions again in nanoseconds since the
+	// Unix epoch, or 0 if it is unknown.
+	retryAt atomic.Int64
+	// openError is the error rejecting the executions, built when the circuit last changed to a
+	// rejecting state.
+	openError atomic.Pointer[OpenError]
+	// windowStart is the time the rolling window was last reset in nanoseconds since the Unix epoch.
+	windowStart atomic.Int64
+	// consecutiveFailures is the number of executions failed in a row.
+	consecutiveFailures atomic.Uint64
+	// consecutiveSuccesses is the number of executions succeeded in a row. It is striped as it is
+	// incremented by every successful execution.
+	consecutiveSuccesses stripedCounter
+	overrideTimer        timerSlot
+	subscribers          subscribers
 }
 
 // New creates a new CircuitBreaker with the passed Configuration.
+// New panics if the configuration is not valid. See Configuration.Validate().
 func New(configuration Configuration) FastBreaker {
+	return newFastBreaker(configuration)
+}
+
+// newFastBreaker creates a new fastBreaker like New.
+func newFastBreaker(configuration Configuration) *fastBreaker {
+	if err := configuration.Validate(); err != nil {
+		panic(err)
+	}
 
 	// Read configuration and applies default values.
 	if configuration.NumBuckets <= 0 {
 		configuration.NumBuckets = DefaultNumBuckets
 	}
 
-	configuration.BucketDuration = configuration.BucketDuration.Truncate(time.Second)
 	if configuration.BucketDuration <= 0 {
 		configuration.BucketDuration = DefaultBucketDuration
 	}
 
-	configuration.DurationOfBreak = configuration.DurationOfBreak.Truncate(time.Second)
 	if configuration.DurationOfBreak <= 0 {
 		configuration.DurationOfBreak = DefaultDurationOfBreak
 	}
 
+	if configuration.WindowSize <= 0 {
+		configuration.WindowSize = DefaultWindowSize
+	}
+
+	if configuration.HalfOpenMaxProbes <= 0 {
+		configuration.HalfOpenMaxProbes = DefaultHalfOpenMaxProbes
+	}
+
+	if configuration.HalfOpenSuccessThreshold <= 0 {
+		configuration.HalfOpenSuccessThreshold = DefaultHalfOpenSuccessThreshold
+	}
+
+	if configuration.HalfOpenProbeTimeout <= 0 {
+		configuration.HalfOpenProbeTimeout = DefaultHalfOpenProbeTimeout
+	}
+
+	if configuration.BreakBackoffMultiplier < 1 {
+		configuration.BreakBackoffMultiplier = DefaultBreakBackoffMultiplier
+	}
+
+	if configuration.MaxDurationOfBreak <= 0 {
+		configuration.MaxDurationOfBreak = DefaultMaxDurationOfBreak
+		if configuration.MaxDurationOfBreak < configuration.DurationOfBreak {
+			configuration.MaxDurationOfBreak = configuration.DurationOfBreak
+		}
+	}
+
+	if configuration.BreakJitter < 0 {
+		configuration.BreakJitter = 0
+	}
+
 	if configuration.ShouldTrip == nil {
 		configuration.ShouldTrip = DefaultShouldTrip
 	}
 
+	if configuration.SlowCallDuration < 0 {
+		configuration.SlowCallDuration = 0
+	}
+
+	if configuration.ShouldTripSlow == nil {
+		configuration.ShouldTripSlow = DefaultShouldTrip
+	}
+
+	if configuration.TripPolicy == nil {
+		configuration.TripPolicy = shouldTripPolicy{
+			shouldTrip:     configuration.ShouldTrip,
+			shouldTripSlow: configuration.ShouldTripSlow,
+		}
+	}
+
+	if configuration.CallTimeout < 0 {
+		configuration.CallTimeout = 0
+	}
+
+	if configuration.Classifier == nil {
+		configuration.Classifier = DefaultClassifier
+	}
+
+	if configuration.Clock == nil {
+		configuration.Clock = RealClock{}
+	}
+
+	if configuration.CounterStripes <= 0 {
+		configuration.CounterStripes = DefaultCounterStripes
+	}
+
 	// Build the circuit breaker.
 	cb := &fastBreaker{
-		configuration: configuration,
-		ring:          ring.New(int(configuration.NumBuckets)),
-		advanceTicker: time.NewTicker(configuration.BucketDuration),
-		totalCounters: &counters{},
+		configuration:        configuration,
+		totalCounters:        newStripedCounters(configuration.CounterStripes),
+		consecutiveSuccesses: newStripedCounter(configuration.CounterStripes),
 	}
 	cb.state.Store(StateStopped)
 
-	// Initialize ring counter.
-	for i := 0; i < cb.ring.Len(); i++ {
-		cb.ring.Value = &counters{}
-		cb.ring = cb.ring.Next()
+	// Build the rolling window.
+	switch configuration.WindowType {
+	case CountBasedWindow:
+		cb.window = newCountWindow(configuration.WindowSize)
+	case LazyTimeBasedWindow:
+		cb.window = newLazyTimeWindow(configuration.NumBuckets, configuration.CounterStripes, configuration.BucketDuration, configuration.Clock)
+	default:
+		cb.window = newTimeWindow(configuration.NumBuckets, configuration.CounterStripes)
 	}
 
-	// Reset the counters.
+	// Reset the counters and start the circuit breaker.
 	cb.totalCounters.reset()
-	cb.reset()
-
-	// Start the advance window goroutine.
-	go cb.advanceWindow()
+	cb.Start()
 
 	return cb
 }
@@ -78,149 +171,525 @@ func (cb *fastBreaker) Configuration() Configuration {
 	return cb.configuration
 }
 
+func (cb *fastBreaker) Start() {
+	cb.lifecycle.Lock()
+	defer cb.lifecycle.Unlock()
+	cb.start()
+}
+
 func (cb *fastBreaker) Stop() {
-	cb.state.Store(StateStopped)
-	if cb.breakTimer != nil {
-		cb.breakTimer.Stop()
+	cb.lifecycle.Lock()
+	defer cb.lifecycle.Unlock()
+	cb.stop()
+}
+
+func (cb *fastBreaker) Restart() {
+	cb.lifecycle.Lock()
+	defer cb.lifecycle.Unlock()
+	cb.stop()
+	cb.start()
+}
+
+// start starts advancing the rolling window and closes the circuit if the circuit breaker is stopped.
+func (cb *fastBreaker) start() {
+	if cb.State() != StateStopped {
+		return
+	}
+
+	// Only time based windows need to be advanced.
+	if w, ok := cb.window.(*timeWindow); ok {
+		cb.advanceTicker = cb.configuration.Clock.NewTicker(cb.configuration.BucketDuration, w.advance)
+	}
+	cb.closeFrom(StateStopped, ReasonStarted)
+}
+
+// stop stops the circuit breaker in any state.
+func (cb *fastBreaker) stop() {
+	state := cb.state.Swap(StateStopped)
+	if state == StateStopped {
+		return
+	}
+	cb.publish(state, StateStopped, ReasonStopped)
+	cb.release()
+}
+
+// stopIfClosed stops the circuit breaker only if it is closed. The state is checked and changed in a
+// single step, so a circuit tripping meanwhile is never stopped.
+// Returns true if the circuit breaker was stopped.
+func (cb *fastBreaker) stopIfClosed() bool {
+	cb.lifecycle.Lock()
+	defer cb.lifecycle.Unlock()
+	if !cb.transition(StateClosed, StateStopped, ReasonStopped) {
+		return false
+	}
+	cb.release()
+	return true
+}
+
+// release stops the circuit breaker timers and waits for the rolling window to stop advancing.
+func (cb *fastBreaker) release() {
+	cb.breakTimer.clear()
+	cb.halfOpenPermits.Store(0)
+	cb.halfOpenProbes.stop()
+
+	// Cancel the expiration of any override.
+	cb.overrideTimer.clear()
+
+	if cb.advanceTicker != nil {
+		cb.advanceTicker.Stop()
+		cb.advanceTicker = nil
+	}
+}
+
+func (cb *fastBreaker) ForceOpen(ttl time.Duration) error {
+	return cb.override(StateForcedOpen, ttl, cb.halfOpenFrom)
+}
+
+func (cb *fastBreaker) ForceClose(ttl time.Duration) error {
+	return cb.override(StateForcedClosed, ttl, cb.closeFrom)
+}
+
+func (cb *fastBreaker) Isolate(ttl time.Duration) error {
+	return cb.override(StateIsolated, ttl, cb.closeFrom)
+}
+
+func (cb *fastBreaker) Subscribe(listener func(StateChange)) func() {
+	ch := make(chan StateChange, DefaultListenerBufferSize)
+	go func() {
+		for change := range ch {
+			listener(change)
+		}
+	}()
+	return cb.subscribers.add(ch, true)
+}
+
+func (cb *fastBreaker) Notify(ch chan<- StateChange) func() {
+	return cb.subscribers.add(ch, false)
+}
+
+func (cb *fastBreaker) ClearOverride() error {
+	for {
+		state := cb.State()
+		switch state {
+		case StateStopped:
+			return ErrCircuitStopped
+		case StateForcedOpen, StateForcedClosed, StateIsolated:
+			cb.overrideTimer.clear()
+			if cb.closeFrom(state, ReasonOverrideCleared) {
+				return nil
+			}
+		default:
+			return nil
+		}
 	}
-	cb.advanceTicker.Stop()
 }
 
 func (cb *fastBreaker) Allow() (func(bool), error) {
-	switch cb.state.Load() {
+	permit, err := cb.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	return permit.report, nil
+}
+
+func (cb *fastBreaker) Acquire() (Permit, error) {
+	state := cb.state.Load()
+	switch state {
 	case StateStopped:
 		// Stopped states rejects all executions.
-		return nil, ErrCircuitStopped
-	case StateClosed:
-		// Closed state allows all executions.
-		return cb.buildFeedbackFunc(StateClosed), nil
+		return Permit{}, ErrCircuitStopped
+	case StateClosed, StateForcedClosed:
+		// Closed and forced closed states allow all executions.
+		return Permit{cb: cb, state: state, start: cb.startTime()}, nil
 	case StateHalfOpen:
-		// Half-open state allows just one execution.
-		if cb.halfOpenAllowed.CompareAndSwap(true, false) {
-			return cb.buildFeedbackFunc(StateHalfOpen), nil
+		// Half-open state allows up to HalfOpenMaxProbes concurrent executions.
+		if cb.acquireHalfOpenPermit() {
+			return Permit{cb: cb, state: state, start: cb.startTime(), probe: newHalfOpenProbe(cb)}, nil
 		}
 	}
-	// Reject other executions.
+	// Reject other executions, including all the executions in the forced open and isolated states.
 	cb.rejected.Add(1)
-	return nil, ErrCircuitOpen
+	if err := cb.openError.Load(); err != nil && err.State == state {
+		return Permit{}, err
+	}
+	// The circuit is changing state and the error of the new state is not built yet.
+	return Permit{}, cb.newOpenError(state)
+}
+
+// newOpenError builds the error rejecting the executions in the passed state.
+func (cb *fastBreaker) newOpenError(state State) *OpenError {
+	err := &OpenError{Name: cb.configuration.Name, State: state, clock: cb.configuration.Clock}
+	if openedAt := cb.openedAt.Load(); openedAt != 0 {
+		err.OpenedAt = time.Unix(0, openedAt)
+	}
+	// Half-open circuits allow executions again as soon as a probe reports.
+	if retryAt := cb.retryAt.Load(); retryAt != 0 && state != StateHalfOpen {
+		err.RetryAt = time.Unix(0, retryAt)
+	}
+	return err
 }
 
 func (cb *fastBreaker) State() State {
-	return cb.state.Load().(State)
+	return cb.state.Load()
 }
 
 func (cb *fastBreaker) Executions() uint64 {
-	return cb.totalCounters.executions.Load()
+	return cb.totalCounters.load().executions
 }
 
 func (cb *fastBreaker) Failures() uint64 {
-	return cb.totalCounters.failures.Load()
+	return cb.totalCounters.load().failures
+}
+
+func (cb *fastBreaker) Timeouts() uint64 {
+	return cb.totalCounters.load().timeouts
 }
 
 func (cb *fastBreaker) Rejected() uint64 {
 	return cb.rejected.Load()
 }
 
+func (cb *fastBreaker) Ignored() uint64 {
+	return cb.ignored.Load()
+}
+
+// activity returns the number of executions counted in Executions, Ignored and Rejected, which keeps
+// changing while the circuit breaker is in use.
+func (cb *fastBreaker) activity() uint64 {
+	return cb.totalCounters.load().executions + cb.ignored.Load() + cb.rejected.Load()
+}
+
+func (cb *fastBreaker) FallbackCounters() (uint64, uint64) {
+	return cb.fallbackSuccesses.Load(), cb.fallbackFailures.Load()
+}
+
+func (cb *fastBreaker) RecordFallback(success bool) {
+	if success {
+		cb.fallbackSuccesses.Add(1)
+	} else {
+		cb.fallbackFailures.Add(1)
+	}
+}
+
+func (cb *fastBreaker) SlowCalls() uint64 {
+	return cb.totalCounters.load().slowCalls
+}
+
+func (cb *fastBreaker) RollingSlowCalls() uint64 {
+	return cb.window.counters().slowCalls
+}
+
 func (cb *fastBreaker) RollingCounters() (uint64, uint64) {
-	var executions uint64 = 0
-	var failures uint64 = 0
-	cb.ring.Do(func(value any) {
-		counter := value.(*counters)
-		executions += counter.executions.Load()
-		failures += counter.failures.Load()
-	})
-	return executions, failures
+	counts := cb.window.counters()
+	return counts.executions, counts.failures
 }
 
-func (cb *fastBreaker) buildFeedbackFunc(state State) func(bool) {
-	return func(success bool) {
-		cb.handleFeedback(state, success)
+func (cb *fastBreaker) ConsecutiveFailures() uint64 {
+	return cb.consecutiveFailures.Load()
+}
+
+func (cb *fastBreaker) ConsecutiveSuccesses() uint64 {
+	return cb.consecutiveSuccesses.load()
+}
+
+func (cb *fastBreaker) Stats() Stats {
+	now := cb.configuration.Clock.Now()
+	counts := cb.window.counters()
+	stats := Stats{
+		Time:                 now,
+		Executions:           counts.executions,
+		Failures:             counts.failures,
+		SlowCalls:            counts.slowCalls,
+		Timeouts:             counts.timeouts,
+		ConsecutiveFailures:  cb.ConsecutiveFailures(),
+		ConsecutiveSuccesses: cb.ConsecutiveSuccesses(),
+		Rejected:             cb.rejected.Load(),
+		WindowElapsed:        cb.windowElapsed(now),
 	}
+	if lastTrip := cb.lastTrip.Load(); lastTrip != 0 {
+		stats.LastTrip = time.Unix(0, lastTrip)
+	}
+	return stats
 }
 
-func (cb *fastBreaker) handleFeedback(executionState State, success bool) {
+// windowElapsed returns the time covered by the rolling window at now.
+func (cb *fastBreaker) windowElapsed(now time.Time) time.Duration {
+	elapsed := now.Sub(time.Unix(0, cb.windowStart.Load()))
+	if cb.configuration.WindowType == CountBasedWindow {
+		return elapsed
+	}
+	if span := time.Duration(cb.configuration.NumBuckets) * cb.configuration.BucketDuration; elapsed > span {
+		return span
+	}
+	return elapsed
+}
+
+// startTime returns the start time of an execution. It returns the zero time when slow call
+// detection is disabled.
+func (cb *fastBreaker) startTime() time.Time {
+	if cb.configuration.SlowCallDuration == 0 {
+		return time.Time{}
+	}
+	return cb.configuration.Clock.Now()
+}
+
+// isSlow returns true if the execution started at start took at least SlowCallDuration.
+func (cb *fastBreaker) isSlow(start time.Time) bool {
+	if cb.configuration.SlowCallDuration == 0 {
+		return false
+	}
+	return cb.configuration.Clock.Now().Sub(start) >= cb.configuration.SlowCallDuration
+}
+
+func (cb *fastBreaker) handleFeedback(executionState State, outcome Outcome, slow bool) {
 	state := cb.state.Load()
 	// Ignore feedback of executions allowed then the circuit was in a different state.
 	if executionState != state {
 		return
 	}
 
+	// Ignored executions are not recorded in the rolling window but release the half-open permit.
+	if outcome == OutcomeIgnored {
+		cb.ignored.Add(1)
+		if state == StateHalfOpen {
+			cb.releaseHalfOpenPermit()
+		}
+		return
+	}
+
+	success := outcome == OutcomeSuccess
+
+	stripe := cb.stripe()
 	switch state {
 	case StateClosed:
-		cb.incExecutions()
-		if !success {
-			cb.incFailures()
-			executions, failures := cb.RollingCounters()
-			// check if the circuit breaker should trip
-			if cb.configuration.ShouldTrip(executions, failures) {
-				cb.tripFrom(StateClosed)
-			}
+		cb.record(stripe, outcome, slow)
+		// check if the circuit breaker should trip
+		if (slow || !success) && cb.configuration.TripPolicy.ShouldTrip(cb.Stats()) {
+			cb.tripFrom(StateClosed, ReasonTripped)
 		}
+	case StateForcedClosed:
+		// Forced closed state counts the executions but never trips.
+		cb.record(stripe, outcome, slow)
 	case StateHalfOpen:
-		if success {
-			cb.reset()
+		// A slow or timed out execution is a failed probe.
+		if !success || slow {
+			cb.streak(stripe, true)
+			cb.tripFrom(StateHalfOpen, ReasonProbeFailed)
+			return
+		}
+		// Close the circuit after HalfOpenSuccessThreshold consecutive successful executions or release
+		// the permit to allow another execution.
+		cb.streak(stripe, false)
+		if cb.ConsecutiveSuccesses() >= uint64(cb.configuration.HalfOpenSuccessThreshold) {
+			cb.closeFrom(StateHalfOpen, ReasonProbeSucceeded)
 		} else {
-			cb.tripFrom(StateHalfOpen)
+			cb.releaseHalfOpenPermit()
+		}
+	}
+}
+
+// acquireHalfOpenPermit takes one of the executions allowed in the half-open state.
+func (cb *fastBreaker) acquireHalfOpenPermit() bool {
+	for {
+		permits := cb.halfOpenPermits.Load()
+		if permits <= 0 {
+			return false
+		}
+		if cb.halfOpenPermits.CompareAndSwap(permits, permits-1) {
+			return true
 		}
 	}
 }
 
-func (cb *fastBreaker) tripFrom(state State) bool {
-	if cb.state.CompareAndSwap(state, StateOpen) {
-		if cb.breakTimer == nil {
-			// Create a timer that will transition the circuit from StateOpen to StateHalfOpen.
-			cb.breakTimer = time.AfterFunc(
-				cb.configuration.DurationOfBreak,
-				func() {
-					if cb.state.CompareAndSwap(StateOpen, StateHalfOpen) {
-						cb.halfOpenAllowed.Store(true)
-					}
-					cb.breakTimer = nil
-				},
-			)
+// releaseHalfOpenPermit gives back one of the executions allowed in the half-open state, unless the
+// circuit left the half-open state meanwhile.
+func (cb *fastBreaker) releaseHalfOpenPermit() {
+	for {
+		permits := cb.halfOpenPermits.Load()
+		if cb.state.Load() != StateHalfOpen {
+			return
 		}
+		if cb.halfOpenPermits.CompareAndSwap(permits, permits+1) {
+			return
+		}
+	}
+}
+
+func (cb *fastBreaker) tripFrom(state State, reason TransitionReason) bool {
+	if cb.transition(state, StateOpen, reason) {
+		now := cb.configuration.Clock.Now()
+		durationOfBreak := cb.durationOfBreak(cb.trips.Add(1))
+		cb.lastTrip.Store(now.UnixNano())
+		cb.openedAt.Store(now.UnixNano())
+		cb.retryAt.Store(now.Add(durationOfBreak).UnixNano())
+		cb.openError.Store(cb.newOpenError(StateOpen))
+		// Arm a timer that will transition the circuit from StateOpen to StateHalfOpen.
+		cb.breakTimer.arm(
+			cb.configuration.Clock,
+			durationOfBreak,
+			func() {
+				cb.halfOpenFrom(StateOpen, ReasonBreakElapsed)
+			},
+		)
 		return true
 	}
 	return false
 }
 
-// reset resets the circuit breaker.
-func (cb *fastBreaker) reset() {
-	// stop the breakTimer.
-	if cb.breakTimer != nil {
-		cb.breakTimer.Stop()
+// halfOpenFrom changes the circuit breaker to the half-open state if it is in the passed state.
+func (cb *fastBreaker) halfOpenFrom(state State, reason TransitionReason) bool {
+	if cb.state.Load() != state {
+		return false
 	}
+	// Prepare the probes before becoming half-open, so every execution allowed in the half-open state
+	// belongs to the new generation.
+	cb.halfOpenGeneration.Add(1)
+	// the probes must succeed in a row from now on.
+	cb.consecutiveSuccesses.reset()
+	cb.halfOpenPermits.Store(int32(cb.configuration.HalfOpenMaxProbes))
+	if cb.transition(state, StateHalfOpen, reason) {
+		cb.openError.Store(cb.newOpenError(StateHalfOpen))
+		return true
+	}
+	return false
+}
 
-	// reset the rolling counters.
-	if cb.state.Swap(StateClosed) != StateClosed {
-		for i := 0; i < cb.ring.Len(); i++ {
-			cb.ring.Value.(*counters).reset()
-			cb.ring = cb.ring.Next()
+// override pins the circuit breaker in the passed state. If ttl is positive, expire is called with
+// the pinned state after ttl to unpin the circuit breaker.
+func (cb *fastBreaker) override(state State, ttl time.Duration, expire func(State, TransitionReason) bool) error {
+	var current State
+	for {
+		current = cb.State()
+		if current == StateStopped {
+			return ErrCircuitStopped
+		}
+		if current == state || cb.transition(current, state, ReasonOverridden) {
+			break
 		}
 	}
+
+	// Record when the rejecting overrides started and when they expire.
+	if state != StateForcedClosed {
+		now := cb.configuration.Clock.Now()
+		if current != state {
+			cb.openedAt.Store(now.UnixNano())
+		}
+		if ttl > 0 {
+			cb.retryAt.Store(now.Add(ttl).UnixNano())
+		} else {
+			cb.retryAt.Store(0)
+		}
+		cb.openError.Store(cb.newOpenError(state))
+	}
+
+	// Replace the expiration of any previous override.
+	if ttl > 0 {
+		cb.overrideTimer.arm(cb.configuration.Clock, ttl, func() {
+			expire(state, ReasonOverrideExpired)
+		})
+	} else {
+		cb.overrideTimer.clear()
+	}
+	return nil
 }
 
-// incExecutions increments the number of executions.
-func (cb *fastBreaker) incExecutions() {
-	cb.totalCounters.executions.Add(1)
-	cb.ring.Value.(*counters).executions.Add(1)
+// durationOfBreak returns the duration of the break after the circuit opened trips times in a row.
+// The DurationOfBreak is multiplied by BreakBackoffMultiplier for every trip after the first one,
+// randomized by BreakJitter and limited to MaxDurationOfBreak.
+func (cb *fastBreaker) durationOfBreak(trips uint32) time.Duration {
+	duration := float64(cb.configuration.DurationOfBreak) *
+		math.Pow(cb.configuration.BreakBackoffMultiplier, float64(trips-1))
+
+	if cb.configuration.BreakJitter > 0 {
+		// Spread the duration uniformly in [duration * (1 - jitter), duration * (1 + jitter)).
+		duration *= 1 + cb.configuration.BreakJitter*(2*rand.Float64()-1)
+	}
+
+	if duration > float64(cb.configuration.MaxDurationOfBreak) {
+		return cb.configuration.MaxDurationOfBreak
+	}
+	if duration < float64(MinDurationOfBreak) {
+		return MinDurationOfBreak
+	}
+	return time.Duration(duration)
 }
 
-// incFailures increments the number of failures.
-func (cb *fastBreaker) incFailures() {
-	cb.totalCounters.failures.Add(1)
-	cb.ring.Value.(*counters).failures.Add(1)
+// closeFrom closes the circuit breaker if it is in the passed state and resets the rolling counters.
+func (cb *fastBreaker) closeFrom(state State, reason TransitionReason) bool {
+	if !cb.transition(state, StateClosed, reason) {
+		return false
+	}
+
+	// stop the breakTimer.
+	cb.breakTimer.clear()
+
+	// reset the break backoff.
+	cb.trips.Store(0)
+
+	// reset the rolling counters.
+	cb.window.reset()
+	cb.consecutiveFailures.Store(0)
+	cb.windowStart.Store(cb.configuration.Clock.Now().UnixNano())
+	return true
 }
 
-// advanceWindow moves the ring to the next value.
-func (cb *fastBreaker) advanceWindow() {
-	for range cb.advanceTicker.C {
-		next := cb.ring.Next()
+// transition changes the circuit breaker state and publishes the change if it is in the from state.
+func (cb *fastBreaker) transition(from State, to State, reason TransitionReason) bool {
+	if !cb.state.CompareAndSwap(from, to) {
+		return false
+	}
+	if from == StateHalfOpen {
+		// Drop the permits left unused, so they can not be taken after leaving the half-open state.
+		cb.halfOpenPermits.Store(0)
+		cb.halfOpenProbes.stop()
+	}
+	cb.publish(from, to, reason)
+	return true
+}
 
-		// reset the next counters.
-		next.Value.(*counters).reset()
+// publish sends a state change to the subscribers.
+func (cb *fastBreaker) publish(from State, to State, reason TransitionReason) {
+	if cb.subscribers.empty() {
+		return
+	}
+	counts := cb.window.counters()
+	cb.subscribers.publish(StateChange{
+		From:       from,
+		To:         to,
+		Reason:     reason,
+		Time:       cb.configuration.Clock.Now(),
+		Executions: counts.executions,
+		Failures:   counts.failures,
+		SlowCalls:  counts.slowCalls,
+	})
+}
+
+// stripe returns a random number to spread the outcome of an execution over the counter stripes.
+func (cb *fastBreaker) stripe() uint32 {
+	if cb.configuration.CounterStripes > 1 {
+		return rand.Uint32()
+	}
+	return 0
+}
+
+// record adds the outcome of an execution to the total counters, to the rolling window and to the
+// consecutive counters.
+func (cb *fastBreaker) record(stripe uint32, outcome Outcome, slow bool) {
+	cb.totalCounters.record(stripe, outcome, slow)
+	cb.window.record(stripe, outcome, slow)
+	cb.streak(stripe, outcome != OutcomeSuccess)
+}
 
-		// advance the ring.
-		cb.ring = next
+// streak adds the outcome of an execution to the consecutive counters.
+func (cb *fastBreaker) streak(stripe uint32, failed bool) {
+	if failed {
+		cb.consecutiveFailures.Add(1)
+		cb.consecutiveSuccesses.reset()
+		return
+	}
+	cb.consecutiveSuccesses.add(stripe)
+	if cb.consecutiveFailures.Load() != 0 {
+		// Avoid writing the shared counter on every successful execution.
+		cb.consecutiveFailures.Store(0)
 	}
 }
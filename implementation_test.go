@@ -1,6 +1,10 @@
 package fastbreaker_test
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,6 +30,110 @@ func TestStoppedCircuitBreaker(t *testing.T) {
 	}
 }
 
+func TestStateString(t *testing.T) {
+	tests := map[fastbreaker.State]string{
+		fastbreaker.StateStopped:      "stopped",
+		fastbreaker.StateClosed:       "closed",
+		fastbreaker.StateHalfOpen:     "half-open",
+		fastbreaker.StateOpen:         "open",
+		fastbreaker.StateForcedOpen:   "forced-open",
+		fastbreaker.StateForcedClosed: "forced-closed",
+		fastbreaker.StateIsolated:     "isolated",
+		fastbreaker.State(100):        "unknown state 100",
+	}
+
+	for state, expected := range tests {
+		if state.String() != expected {
+			t.Errorf("expected %q but got %q.", expected, state.String())
+		}
+	}
+}
+
+func TestRestart(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+
+	// Trip the circuit and stop it twice.
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	cb.Stop()
+	cb.Stop()
+	assertStateAndCounters(t, cb, fastbreaker.StateStopped, 20, 20)
+	allowAndAssert(t, cb, false)
+
+	// Start should close the circuit and reset the rolling window but keep the total counters.
+	cb.Start()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 20, 20)
+	assertRollingCounters(t, cb, 0, 0)
+	allowAndAssert(t, cb, true)(true)
+
+	// Start should do nothing if the circuit breaker is already started.
+	cb.Start()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 21, 20)
+	assertRollingCounters(t, cb, 1, 0)
+
+	// The rolling window should keep advancing after a restart.
+	for i := 0; i < cb.Configuration().NumBuckets; i++ {
+		clock.Advance(cb.Configuration().BucketDuration)
+	}
+	assertRollingCounters(t, cb, 0, 0)
+
+	// Restart should close an open circuit.
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	cb.Restart()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 41, 40)
+	assertRollingCounters(t, cb, 0, 0)
+
+	// The break timer of the stopped circuit should not fire.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 41, 40)
+	cb.Stop()
+}
+
+func TestConcurrentLifecycle(t *testing.T) {
+	cb := fastbreaker.New(fastbreaker.Configuration{BucketDuration: fastbreaker.MinBucketDuration})
+	defer cb.Stop()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if feedback, err := cb.Allow(); err == nil {
+					feedback(true)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 99; i++ {
+		switch i % 3 {
+		case 0:
+			cb.Stop()
+		case 1:
+			cb.Start()
+		case 2:
+			cb.Restart()
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+}
+
 func TestCircuitBreaker(t *testing.T) {
 	const numExecutions = 1_000
 
@@ -37,9 +145,11 @@ func TestCircuitBreaker(t *testing.T) {
 	}
 
 	// Build circuit breaker and assert initial state.
+	clock := newFakeClock()
 	cb := fastbreaker.New(fastbreaker.Configuration{
 		DurationOfBreak: 1 * time.Second,
 		ShouldTrip:      shouldTripWrapper,
+		Clock:           clock,
 	})
 	defer cb.Stop()
 	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 0, 0)
@@ -84,7 +194,7 @@ func TestCircuitBreaker(t *testing.T) {
 
 	// The circuit breaker should remain open for DurationOfBreak and then should change to half-open.
 	// During this time no execution should be allowed.
-	assertStateOpenForDurationOfBreak(t, cb, openFeedbackFunc)
+	assertStateOpenForDurationOfBreak(t, cb, clock, openFeedbackFunc)
 	if shouldTripExecutions != totalFailures {
 		t.Fatalf("expected %d executions of the ShouldTripFunc but got %d", totalFailures, shouldTripExecutions)
 	}
@@ -107,7 +217,7 @@ func TestCircuitBreaker(t *testing.T) {
 	}
 	assertStateAndCounters(t, cb, fastbreaker.StateOpen, totalExecutions, totalFailures)
 
-	assertStateOpenForDurationOfBreak(t, cb, feedback)
+	assertStateOpenForDurationOfBreak(t, cb, clock, feedback)
 	if shouldTripExecutions != totalFailures {
 		t.Fatalf("expected %d executions of the ShouldTripFunc but got %d", totalFailures, shouldTripExecutions)
 	}
@@ -127,33 +237,568 @@ func TestCircuitBreaker(t *testing.T) {
 	assertStateAndCounters(t, cb, fastbreaker.StateClosed, totalExecutions, totalFailures)
 
 	// The circuit breaker should not change to Half-Open after DurationOfBreak.
-	time.Sleep(cb.Configuration().DurationOfBreak)
+	clock.Advance(cb.Configuration().DurationOfBreak)
 	assertStateAndCounters(t, cb, fastbreaker.StateClosed, totalExecutions, totalFailures)
-	time.Sleep(cb.Configuration().DurationOfBreak)
+	clock.Advance(cb.Configuration().DurationOfBreak)
 	assertStateAndCounters(t, cb, fastbreaker.StateClosed, totalExecutions, totalFailures)
 }
 
 func TestRollingCounters(t *testing.T) {
-	cb := fastbreaker.New(fastbreaker.Configuration{})
+	configurations := map[string]fastbreaker.Configuration{
+		"time":              {WindowType: fastbreaker.TimeBasedWindow},
+		"lazy time":         {WindowType: fastbreaker.LazyTimeBasedWindow},
+		"striped time":      {WindowType: fastbreaker.TimeBasedWindow, CounterStripes: 8},
+		"striped lazy time": {WindowType: fastbreaker.LazyTimeBasedWindow, CounterStripes: 8},
+	}
+
+	for name, configuration := range configurations {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			configuration.Clock = clock
+			cb := fastbreaker.New(configuration)
+			configuration := cb.Configuration()
+
+			clock.Advance(configuration.BucketDuration / 2)
+
+			// generate a successful execution in every bucket.
+			for i := 1; i <= configuration.NumBuckets; i++ {
+				feedback := allowAndAssert(t, cb, true)
+				feedback(true)
+				assertRollingCounters(t, cb, i, 0)
+				clock.Advance(configuration.BucketDuration)
+			}
+
+			// check that after every BucketDuration an execution is removed.
+			for i := configuration.NumBuckets - 1; i > 0; i-- {
+				assertRollingCounters(t, cb, i, 0)
+				clock.Advance(configuration.BucketDuration)
+			}
+
+			// check that the window is empty after being idle for a long time.
+			feedback := allowAndAssert(t, cb, true)
+			feedback(false)
+			assertRollingCounters(t, cb, 1, 1)
+			clock.Advance(100 * time.Duration(configuration.NumBuckets) * configuration.BucketDuration)
+			assertRollingCounters(t, cb, 0, 0)
+
+			cb.Stop()
+		})
+	}
+}
+
+func TestLazyTimeBasedWindowGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// Lazy time based windows should not start a goroutine per circuit breaker.
+	breakers := make([]fastbreaker.FastBreaker, 100)
+	for i := range breakers {
+		breakers[i] = fastbreaker.New(fastbreaker.Configuration{WindowType: fastbreaker.LazyTimeBasedWindow})
+		allowAndAssert(t, breakers[i], true)(true)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected at most %d goroutines but got %d.", before, after)
+	}
+
+	for _, cb := range breakers {
+		cb.Stop()
+	}
+}
+
+func TestHalfOpenProbes(t *testing.T) {
+	const maxProbes = 3
+	const successThreshold = 5
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		HalfOpenMaxProbes:        maxProbes,
+		HalfOpenSuccessThreshold: successThreshold,
+		Clock:                    clock,
+	})
+	defer cb.Stop()
+
+	// A failed probe should open the circuit again.
+	tripAndWaitHalfOpen(t, cb, clock)
+	feedbacks := make([]func(bool), 0, maxProbes)
+	for i := 0; i < maxProbes; i++ {
+		feedbacks = append(feedbacks, allowAndAssert(t, cb, true))
+	}
+	allowAndAssert(t, cb, false)
+	feedbacks[0](true)
+	feedbacks[1](false)
+	if cb.State() != fastbreaker.StateOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateOpen, cb.State())
+	}
+
+	// Every successful probe should release its permit until successThreshold probes succeed.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	feedbacks = feedbacks[:0]
+	for i := 0; i < maxProbes; i++ {
+		feedbacks = append(feedbacks, allowAndAssert(t, cb, true))
+	}
+	for i := 0; i < successThreshold-1; i++ {
+		allowAndAssert(t, cb, false)
+		feedbacks[0](true)
+		feedbacks = append(feedbacks[1:], allowAndAssert(t, cb, true))
+		if cb.State() != fastbreaker.StateHalfOpen {
+			t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+		}
+	}
+	feedbacks[0](true)
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+}
+
+func TestConsecutiveCounters(t *testing.T) {
+	const successThreshold = 3
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		HalfOpenSuccessThreshold: successThreshold,
+		TripPolicy:               fastbreaker.ConsecutiveFailures(5),
+		Clock:                    clock,
+	})
+	defer cb.Stop()
+
+	// Every outcome should break the streak of the other one.
+	for i := 1; i <= 3; i++ {
+		allowAndAssert(t, cb, true)(true)
+		assertConsecutiveCounters(t, cb, 0, uint64(i))
+	}
+	for i := 1; i <= 4; i++ {
+		allowAndAssert(t, cb, true)(false)
+		assertConsecutiveCounters(t, cb, uint64(i), 0)
+	}
+	allowAndAssert(t, cb, true)(true)
+	assertConsecutiveCounters(t, cb, 0, 1)
+
+	// A failed probe should extend the streak of failures.
+	tripAndWaitHalfOpen(t, cb, clock)
+	assertConsecutiveCounters(t, cb, 5, 0)
+	allowAndAssert(t, cb, true)(false)
+	assertConsecutiveCounters(t, cb, 6, 0)
+
+	// The circuit should close after successThreshold probes succeed in a row.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	for i := 1; i <= successThreshold; i++ {
+		if cb.State() != fastbreaker.StateHalfOpen {
+			t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+		}
+		allowAndAssert(t, cb, true)(true)
+		assertConsecutiveCounters(t, cb, 0, uint64(i))
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 13, 9)
+
+	stats := cb.Stats()
+	if stats.ConsecutiveFailures != 0 || stats.ConsecutiveSuccesses != successThreshold {
+		t.Fatalf("unexpected stats %+v.", stats)
+	}
+}
+
+func assertConsecutiveCounters(t *testing.T, cb fastbreaker.FastBreaker, expectedFailures uint64, expectedSuccesses uint64) {
+	t.Helper()
+
+	if cb.ConsecutiveFailures() != expectedFailures {
+		t.Fatalf("%d consecutive failures expected but got %d instead.", expectedFailures, cb.ConsecutiveFailures())
+	}
+	if cb.ConsecutiveSuccesses() != expectedSuccesses {
+		t.Fatalf("%d consecutive successes expected but got %d instead.", expectedSuccesses, cb.ConsecutiveSuccesses())
+	}
+}
+
+func TestHalfOpenProbeTimeout(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		HalfOpenProbeTimeout: 2 * time.Second,
+		Clock:                clock,
+	})
+	defer cb.Stop()
 	configuration := cb.Configuration()
 
-	time.Sleep(configuration.BucketDuration / 2)
+	// An unreported probe should open the circuit after HalfOpenProbeTimeout.
+	tripAndWaitHalfOpen(t, cb, clock)
+	lostFeedback := allowAndAssert(t, cb, true)
+	clock.Advance(configuration.HalfOpenProbeTimeout - time.Millisecond)
+	allowAndAssert(t, cb, false)
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
+	clock.Advance(time.Millisecond)
+	if cb.State() != fastbreaker.StateOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateOpen, cb.State())
+	}
+
+	// The break timer should be armed again.
+	clock.Advance(configuration.DurationOfBreak)
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
+
+	// The late feedback of the timed out probe should be ignored.
+	lostFeedback(true)
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
+
+	// A reported probe should not time out.
+	allowAndAssert(t, cb, true)(true)
+	clock.Advance(configuration.HalfOpenProbeTimeout)
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+}
+
+func TestHalfOpenProbeTimeoutAfterClose(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		HalfOpenMaxProbes: 3,
+		TripPolicy:        fastbreaker.ConsecutiveFailures(2),
+		Clock:             clock,
+	})
+	defer cb.Stop()
+
+	// A probe left unreported when the circuit closes should not time out the closed circuit.
+	tripAndWaitHalfOpen(t, cb, clock)
+	lostFeedback := allowAndAssert(t, cb, true)
+	allowAndAssert(t, cb, true)(true)
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+	clock.Advance(cb.Configuration().HalfOpenProbeTimeout)
+	assertConsecutiveCounters(t, cb, 0, 1)
+
+	// A single failure should not trip the circuit.
+	allowAndAssert(t, cb, true)(false)
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+
+	// The late feedback of the probe should be ignored.
+	lostFeedback(false)
+	assertConsecutiveCounters(t, cb, 1, 0)
+}
+
+// hookClock is a FakeClock calling hook every time the time is read.
+type hookClock struct {
+	*fastbreaker.FakeClock
+	hook func()
+}
+
+func (c *hookClock) Now() time.Time {
+	if c.hook != nil {
+		c.hook()
+	}
+	return c.FakeClock.Now()
+}
+
+func TestHalfOpenLeftoverPermits(t *testing.T) {
+	clock := &hookClock{FakeClock: newFakeClock()}
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		HalfOpenMaxProbes: 3,
+		Clock:             clock,
+	})
+	defer cb.Stop()
+	// Read the time right after every state change.
+	cb.Notify(make(chan fastbreaker.StateChange, 16))
+
+	// A failed probe should not leave its unused permits to the next half-open state.
+	tripAndWaitHalfOpen(t, cb, clock.FakeClock)
+	allowAndAssert(t, cb, true)(false)
+
+	// Acquire a probe as soon as the circuit becomes half-open again.
+	var probes []fastbreaker.Permit
+	clock.hook = func() {
+		if cb.State() == fastbreaker.StateHalfOpen && len(probes) == 0 {
+			if permit, err := cb.Acquire(); err == nil {
+				probes = append(probes, permit)
+			}
+		}
+	}
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	clock.hook = nil
+	for {
+		permit, err := cb.Acquire()
+		if err != nil {
+			break
+		}
+		probes = append(probes, permit)
+	}
+	if len(probes) != 3 {
+		t.Fatalf("expected 3 probes but got %d.", len(probes))
+	}
+
+	// Every probe should belong to the half-open state.
+	probes[0].Failure()
+	if cb.State() != fastbreaker.StateOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateOpen, cb.State())
+	}
+
+	// The late feedback of the other probes should not release permits while the circuit is open.
+	probes[1].Ignore()
+	probes[2].Success()
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	for i := 0; i < 3; i++ {
+		allowAndAssert(t, cb, true)
+	}
+	allowAndAssert(t, cb, false)
+}
+
+func TestBreakBackoff(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		DurationOfBreak:        1 * time.Second,
+		BreakBackoffMultiplier: 2,
+		MaxDurationOfBreak:     3 * time.Second,
+		Clock:                  clock,
+	})
+	defer cb.Stop()
+
+	// Every failed probe should double the duration of the break up to MaxDurationOfBreak.
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	for _, expected := range []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if actual := measureBreak(cb, clock); actual != expected {
+			t.Fatalf("expected a break of %s but got %s.", expected, actual)
+		}
+		allowAndAssert(t, cb, true)(false)
+	}
+
+	// Closing the circuit should reset the duration of the break.
+	measureBreak(cb, clock)
+	allowAndAssert(t, cb, true)(true)
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	if actual := measureBreak(cb, clock); actual != 1*time.Second {
+		t.Fatalf("expected a break of %s but got %s.", 1*time.Second, actual)
+	}
+}
+
+func TestBreakJitter(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		DurationOfBreak: 1 * time.Second,
+		BreakJitter:     0.5,
+		Clock:           clock,
+	})
+	defer cb.Stop()
 
-	// generate a successful execution in every bucket.
-	for i := 1; i <= configuration.NumBuckets; i++ {
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	durations := make(map[time.Duration]bool)
+	for i := 0; i < 10; i++ {
+		duration := measureBreak(cb, clock)
+		if duration < 500*time.Millisecond || duration > 1500*time.Millisecond {
+			t.Fatalf("break of %s out of the jitter range.", duration)
+		}
+		durations[duration] = true
+		allowAndAssert(t, cb, true)(false)
+	}
+	if len(durations) == 1 {
+		t.Fatal("breaks should be randomized by the jitter.")
+	}
+}
+
+// measureBreak advances the clock until the circuit leaves the open state and returns the elapsed time.
+func measureBreak(cb fastbreaker.FastBreaker, clock *fastbreaker.FakeClock) time.Duration {
+	start := clock.Now()
+	for cb.State() == fastbreaker.StateOpen {
+		clock.Advance(1 * time.Millisecond)
+	}
+	return clock.Now().Sub(start)
+}
+
+func TestSlowCalls(t *testing.T) {
+	const slowCallDuration = 100 * time.Millisecond
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		SlowCallDuration: slowCallDuration,
+		Clock:            clock,
+	})
+	defer cb.Stop()
+
+	// Fast calls should not be slow calls.
+	for i := 0; i < 20; i++ {
 		feedback := allowAndAssert(t, cb, true)
+		clock.Advance(slowCallDuration - time.Millisecond)
 		feedback(true)
-		assertRollingCounters(t, cb, i, 0)
-		time.Sleep(configuration.BucketDuration)
 	}
+	assertSlowCalls(t, cb, 0, 0)
 
-	// check that after every BucketDuration an execution is removed.
-	for i := configuration.NumBuckets - 1; i > 0; i-- {
-		assertRollingCounters(t, cb, i, 0)
-		time.Sleep(configuration.BucketDuration)
+	// Slow calls should trip the circuit even when they succeed.
+	for i := 1; i <= 20; i++ {
+		feedback := allowAndAssert(t, cb, true)
+		clock.Advance(slowCallDuration)
+		feedback(true)
+		assertSlowCalls(t, cb, uint64(i), uint64(i))
 	}
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 40, 0)
+
+	// A slow probe should open the circuit again.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	feedback := allowAndAssert(t, cb, true)
+	clock.Advance(slowCallDuration)
+	feedback(true)
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 40, 0)
 
+	// A fast probe should close the circuit and reset the rolling slow calls.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	allowAndAssert(t, cb, true)(true)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 40, 0)
+	assertSlowCalls(t, cb, 20, 0)
+}
+
+func TestTripPolicy(t *testing.T) {
+	const durationOfBreak = 10 * time.Second
+
+	var lastStats fastbreaker.Stats
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		DurationOfBreak: durationOfBreak,
+		TripPolicy: fastbreaker.TripPolicyFunc(func(stats fastbreaker.Stats) bool {
+			lastStats = stats
+			return fastbreaker.ConsecutiveFailures(3).ShouldTrip(stats)
+		}),
+		Clock: clock,
+	})
+	defer cb.Stop()
+
+	// The policy should receive the consecutive failures and the time covered by the window.
+	allowAndAssert(t, cb, true)(false)
+	allowAndAssert(t, cb, true)(false)
+	allowAndAssert(t, cb, true)(true)
+	clock.Advance(time.Second)
+	allowAndAssert(t, cb, true)(false)
+	allowAndAssert(t, cb, true)(false)
+	if lastStats.ConsecutiveFailures != 2 || lastStats.Executions != 5 || lastStats.Failures != 4 {
+		t.Fatalf("unexpected stats %+v.", lastStats)
+	}
+	if lastStats.WindowElapsed != time.Second {
+		t.Fatalf("expected window elapsed %s but got %s.", time.Second, lastStats.WindowElapsed)
+	}
+	if !lastStats.LastTrip.IsZero() {
+		t.Fatalf("expected no last trip but got %s.", lastStats.LastTrip)
+	}
+
+	allowAndAssert(t, cb, true)(false)
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 6, 5)
+	tripTime := clock.Now()
+
+	// The stats should carry the rejections and the last trip.
+	allowAndAssert(t, cb, false)
+	clock.Advance(durationOfBreak)
+	stats := cb.Stats()
+	if stats.Rejected != 1 || !stats.LastTrip.Equal(tripTime) || stats.SinceLastTrip() != durationOfBreak {
+		t.Fatalf("unexpected stats %+v.", stats)
+	}
+
+	// Closing the circuit should reset the consecutive failures and the window.
+	allowAndAssert(t, cb, true)(true)
+	allowAndAssert(t, cb, true)(false)
+	allowAndAssert(t, cb, true)(false)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 8, 7)
+	if lastStats.ConsecutiveFailures != 2 || lastStats.WindowElapsed != 0 {
+		t.Fatalf("unexpected stats %+v.", lastStats)
+	}
+
+	// The window elapsed time should be limited to the time covered by the buckets.
+	configuration := cb.Configuration()
+	clock.Advance(100 * configuration.BucketDuration)
+	windowSpan := time.Duration(configuration.NumBuckets) * configuration.BucketDuration
+	if elapsed := cb.Stats().WindowElapsed; elapsed != windowSpan {
+		t.Fatalf("expected window elapsed %s but got %s.", windowSpan, elapsed)
+	}
+}
+
+func assertSlowCalls(t *testing.T, cb fastbreaker.FastBreaker, expectedSlowCalls uint64, expectedRollingSlowCalls uint64) {
+	t.Helper()
+
+	if cb.SlowCalls() != expectedSlowCalls {
+		t.Fatalf("%d slow calls expected but got %d instead.", expectedSlowCalls, cb.SlowCalls())
+	}
+	if cb.RollingSlowCalls() != expectedRollingSlowCalls {
+		t.Fatalf("%d rolling slow calls expected but got %d instead.", expectedRollingSlowCalls, cb.RollingSlowCalls())
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+
+	// ForceOpen without ttl should reject executions until the override is cleared.
+	assertOverride(t, cb, cb.ForceOpen(0), fastbreaker.StateForcedOpen, fastbreaker.ErrCircuitForcedOpen)
+	clock.Advance(time.Hour)
+	assertOverride(t, cb, nil, fastbreaker.StateForcedOpen, fastbreaker.ErrCircuitForcedOpen)
+	assertOverride(t, cb, cb.ClearOverride(), fastbreaker.StateClosed, nil)
+
+	// ForceOpen with ttl should become half-open after ttl.
+	assertOverride(t, cb, cb.ForceOpen(time.Minute), fastbreaker.StateForcedOpen, fastbreaker.ErrCircuitForcedOpen)
+	clock.Advance(time.Minute)
+	assertOverride(t, cb, nil, fastbreaker.StateHalfOpen, nil)
+
+	// Isolate with ttl should become closed after ttl.
+	assertOverride(t, cb, cb.Isolate(time.Minute), fastbreaker.StateIsolated, fastbreaker.ErrCircuitIsolated)
+	clock.Advance(time.Minute)
+	assertOverride(t, cb, nil, fastbreaker.StateClosed, nil)
+
+	// ForceClose should not trip and should become closed after ttl.
+	assertOverride(t, cb, cb.ForceClose(time.Minute), fastbreaker.StateForcedClosed, nil)
+	for i := 0; i < 100; i++ {
+		allowAndAssert(t, cb, true)(false)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateForcedClosed, 103, 100)
+	clock.Advance(time.Minute)
+	assertOverride(t, cb, nil, fastbreaker.StateClosed, nil)
+
+	// A new override should cancel the expiration of the previous one.
+	assertOverride(t, cb, cb.ForceOpen(time.Minute), fastbreaker.StateForcedOpen, fastbreaker.ErrCircuitForcedOpen)
+	assertOverride(t, cb, cb.Isolate(0), fastbreaker.StateIsolated, fastbreaker.ErrCircuitIsolated)
+	clock.Advance(time.Minute)
+	assertOverride(t, cb, nil, fastbreaker.StateIsolated, fastbreaker.ErrCircuitIsolated)
+
+	// A stopped circuit breaker can not be overridden.
 	cb.Stop()
+	assertOverride(t, cb, cb.ForceClose(0), fastbreaker.StateStopped, fastbreaker.ErrCircuitStopped)
+	if err := cb.ClearOverride(); err != fastbreaker.ErrCircuitStopped {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrCircuitStopped, err)
+	}
+}
+
+// assertOverride checks the error returned by an override method, the state of the circuit breaker
+// and the error returned by Allow.
+func assertOverride(t *testing.T, cb fastbreaker.FastBreaker, overrideErr error, expectedState fastbreaker.State, expectedErr error) {
+	t.Helper()
+
+	if overrideErr != nil && overrideErr != expectedErr {
+		t.Fatalf("unexpected override error %v.", overrideErr)
+	}
+
+	if cb.State() != expectedState {
+		t.Fatalf("circuit breaker should be %s but it is %s.", expectedState, cb.State())
+	}
+
+	feedback, err := cb.Allow()
+	if !errors.Is(err, expectedErr) {
+		t.Fatalf("expected %v from Allow but got %v.", expectedErr, err)
+	}
+	if feedback != nil {
+		feedback(true)
+	}
+}
+
+func tripAndWaitHalfOpen(t *testing.T, cb fastbreaker.FastBreaker, clock *fastbreaker.FakeClock) {
+	t.Helper()
+
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
 }
 
 func allowAndAssert(t *testing.T, cb fastbreaker.FastBreaker, allowed bool) func(bool) {
@@ -171,10 +816,14 @@ func allowAndAssert(t *testing.T, cb fastbreaker.FastBreaker, allowed bool) func
 	return report
 }
 
-func assertStateOpenForDurationOfBreak(t *testing.T, cb fastbreaker.FastBreaker, feedbackFunc func(bool)) {
+func newFakeClock() *fastbreaker.FakeClock {
+	return fastbreaker.NewFakeClock(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func assertStateOpenForDurationOfBreak(t *testing.T, cb fastbreaker.FastBreaker, clock *fastbreaker.FakeClock, feedbackFunc func(bool)) {
 	t.Helper()
 
-	tripedAt := time.Now()
+	tripedAt := clock.Now()
 	prevRejected := cb.Rejected()
 	for cb.State() == fastbreaker.StateOpen {
 		// Executions should not be allowed when in the open state.
@@ -191,10 +840,10 @@ func assertStateOpenForDurationOfBreak(t *testing.T, cb fastbreaker.FastBreaker,
 		}
 		prevRejected = rejected
 
-		time.Sleep(1 * time.Millisecond)
+		clock.Advance(1 * time.Millisecond)
 	}
 
-	if time.Since(tripedAt) < cb.Configuration().DurationOfBreak {
+	if clock.Now().Sub(tripedAt) < cb.Configuration().DurationOfBreak {
 		t.Fatal("circuit should remain open for DurationOfBreak.")
 	}
 }
@@ -230,3 +879,416 @@ func assertRollingCounters(t *testing.T, cb fastbreaker.FastBreaker, expectedExe
 		t.Fatalf("%d executions expected instead of %d.", expectedExecutions, actualExecutions)
 	}
 }
+
+func TestCountBasedWindow(t *testing.T) {
+	const windowSize = 10
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		WindowType: fastbreaker.CountBasedWindow,
+		WindowSize: windowSize,
+		ShouldTrip: func(executions uint64, failures uint64) bool {
+			return executions >= windowSize && failures*2 >= executions
+		},
+		Clock: clock,
+	})
+	defer cb.Stop()
+
+	// Outcomes should remain in the window regardless of the time passed.
+	for i := 1; i <= windowSize/2; i++ {
+		allowAndAssert(t, cb, true)(false)
+		clock.Advance(time.Hour)
+		assertRollingCounters(t, cb, i, i)
+	}
+
+	// Newer outcomes should replace the oldest ones.
+	for i := 1; i <= windowSize/2; i++ {
+		allowAndAssert(t, cb, true)(true)
+		assertRollingCounters(t, cb, windowSize/2+i, windowSize/2)
+	}
+	for i := 1; i < windowSize/2; i++ {
+		allowAndAssert(t, cb, true)(true)
+		assertRollingCounters(t, cb, windowSize, windowSize/2-i)
+	}
+
+	// windowSize/2 failures in a row should trip the circuit.
+	for i := 1; i <= windowSize/2; i++ {
+		allowAndAssert(t, cb, true)(false)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 2*windowSize-1, windowSize)
+
+	// Closing the circuit should reset the window.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	allowAndAssert(t, cb, true)(true)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 2*windowSize-1, windowSize)
+	assertRollingCounters(t, cb, 0, 0)
+}
+
+func TestSubSecondDurations(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		NumBuckets:      20,
+		BucketDuration:  100 * time.Millisecond,
+		DurationOfBreak: 250 * time.Millisecond,
+		Clock:           clock,
+	})
+	defer cb.Stop()
+
+	// A failure every 50ms trips the circuit after 1s.
+	for i := 1; i <= 20; i++ {
+		clock.Advance(50 * time.Millisecond)
+		feedback := allowAndAssert(t, cb, true)
+		feedback(false)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 20, 20)
+
+	// The circuit should remain open for 250ms.
+	clock.Advance(249 * time.Millisecond)
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 20, 20)
+	clock.Advance(1 * time.Millisecond)
+	assertStateAndCounters(t, cb, fastbreaker.StateHalfOpen, 20, 20)
+
+	// A success closes the circuit.
+	allowAndAssert(t, cb, true)(true)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 20, 20)
+
+	// An execution should remain in the 2s rolling window for 20 rotations of 100ms.
+	allowAndAssert(t, cb, true)(true)
+	for i := 1; i < 20; i++ {
+		clock.Advance(100 * time.Millisecond)
+		assertRollingCounters(t, cb, 1, 0)
+	}
+	clock.Advance(100 * time.Millisecond)
+	assertRollingCounters(t, cb, 0, 0)
+}
+
+func TestAcquire(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	// Permits should report the outcome of the executions.
+	for i := 0; i < 10; i++ {
+		permit, err := cb.Acquire()
+		if err != nil {
+			t.Fatalf("unexpected error %v.", err)
+		}
+		permit.Success()
+	}
+	for cb.State() == fastbreaker.StateClosed {
+		permit, err := cb.Acquire()
+		if err != nil {
+			t.Fatalf("unexpected error %v.", err)
+		}
+		permit.Failure()
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 20, 10)
+
+	// Rejected executions should return an error and a zero permit.
+	permit, err := cb.Acquire()
+	if !errors.Is(err, fastbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrCircuitOpen, err)
+	}
+	permit.Success()
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 20, 10)
+
+	// A half-open permit should close the circuit.
+	clock.Advance(cb.Configuration().DurationOfBreak)
+	permit, err = cb.Acquire()
+	if err != nil {
+		t.Fatalf("unexpected error %v.", err)
+	}
+	if _, err := cb.Acquire(); !errors.Is(err, fastbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrCircuitOpen, err)
+	}
+	permit.Success()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 20, 10)
+}
+
+func TestOpenError(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Name: "payments", Clock: clock})
+	defer cb.Stop()
+
+	// An open circuit should report when it opened and when it becomes half-open.
+	for cb.State() == fastbreaker.StateClosed {
+		allowAndAssert(t, cb, true)(false)
+	}
+	openedAt := clock.Now()
+	clock.Advance(time.Second)
+	_, err := cb.Allow()
+	assertOpenError(t, err, fastbreaker.StateOpen, openedAt, 4*time.Second, fastbreaker.ErrCircuitOpen)
+	if err.Error() != "payments: circuit breaker is open, retry after 4s" {
+		t.Fatalf("unexpected error message %q.", err.Error())
+	}
+
+	// A half-open circuit without permits should not estimate the retry.
+	clock.Advance(4 * time.Second)
+	allowAndAssert(t, cb, true)
+	_, err = cb.Acquire()
+	assertOpenError(t, err, fastbreaker.StateHalfOpen, openedAt, 0, fastbreaker.ErrCircuitOpen)
+	if err.Error() != "payments: circuit breaker is open" {
+		t.Fatalf("unexpected error message %q.", err.Error())
+	}
+
+	// The overrides should report when they started and when they expire.
+	openedAt = clock.Now()
+	cb.ForceOpen(time.Minute)
+	clock.Advance(time.Second)
+	_, err = cb.Allow()
+	assertOpenError(t, err, fastbreaker.StateForcedOpen, openedAt, 59*time.Second, fastbreaker.ErrCircuitForcedOpen)
+	cb.ForceOpen(0)
+	_, err = cb.Allow()
+	assertOpenError(t, err, fastbreaker.StateForcedOpen, openedAt, 0, fastbreaker.ErrCircuitForcedOpen)
+	openedAt = clock.Now()
+	cb.Isolate(time.Minute)
+	_, err = cb.Allow()
+	assertOpenError(t, err, fastbreaker.StateIsolated, openedAt, time.Minute, fastbreaker.ErrCircuitIsolated)
+
+	// A stopped circuit breaker should not return an OpenError.
+	cb.Stop()
+	if _, err = cb.Allow(); err != fastbreaker.ErrCircuitStopped {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrCircuitStopped, err)
+	}
+}
+
+func assertOpenError(t *testing.T, err error, expectedState fastbreaker.State, expectedOpenedAt time.Time, expectedRetryAfter time.Duration, expectedErr error) {
+	t.Helper()
+
+	var openErr *fastbreaker.OpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected an OpenError but got %v.", err)
+	}
+	if !errors.Is(err, expectedErr) {
+		t.Fatalf("expected %v but got %v.", expectedErr, err)
+	}
+	if openErr.Name != "payments" || openErr.State != expectedState {
+		t.Fatalf("expected payments in %s but got %s in %s.", expectedState, openErr.Name, openErr.State)
+	}
+	if !openErr.OpenedAt.Equal(expectedOpenedAt) || openErr.RetryAfter() != expectedRetryAfter {
+		t.Fatalf("expected opened at %s and retry after %s but got %s and %s.", expectedOpenedAt, expectedRetryAfter, openErr.OpenedAt, openErr.RetryAfter())
+	}
+}
+
+func TestIgnoredOutcome(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{Clock: clock})
+	defer cb.Stop()
+
+	acquire := func() fastbreaker.Permit {
+		t.Helper()
+		permit, err := cb.Acquire()
+		if err != nil {
+			t.Fatalf("unexpected error %v.", err)
+		}
+		return permit
+	}
+
+	// Ignored executions should not be counted in the closed state.
+	acquire().Success()
+	acquire().Ignore()
+	acquire().Report(fastbreaker.OutcomeIgnored)
+	acquire().Report(fastbreaker.OutcomeFailure)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 2, 1)
+	assertRollingCounters(t, cb, 2, 1)
+	assertConsecutiveCounters(t, cb, 1, 0)
+	if cb.Ignored() != 2 {
+		t.Fatalf("2 ignored executions expected but got %d instead.", cb.Ignored())
+	}
+
+	// An ignored probe should release its permit and cancel its timeout.
+	tripAndWaitHalfOpen(t, cb, clock)
+	stale := acquire()
+	stale.Ignore()
+	clock.Advance(cb.Configuration().HalfOpenProbeTimeout)
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
+
+	// Reporting an ignored permit again should do nothing.
+	probe := acquire()
+	stale.Failure()
+	if cb.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+	}
+	probe.Success()
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 20, 19)
+	if cb.Ignored() != 3 {
+		t.Fatalf("3 ignored executions expected but got %d instead.", cb.Ignored())
+	}
+}
+
+var errAllocations = errors.New("allocations")
+
+func TestAcquireAllocations(t *testing.T) {
+	configurations := map[string]fastbreaker.Configuration{
+		"default":   {},
+		"slow":      {SlowCallDuration: time.Second},
+		"count":     {WindowType: fastbreaker.CountBasedWindow},
+		"lazy time": {WindowType: fastbreaker.LazyTimeBasedWindow},
+		"striped":   {CounterStripes: 8},
+	}
+
+	for name, configuration := range configurations {
+		t.Run(name, func(t *testing.T) {
+			// Keep the circuit closed.
+			configuration.ShouldTrip = func(executions uint64, failures uint64) bool { return false }
+			cb := fastbreaker.New(configuration)
+			defer cb.Stop()
+
+			allocations := testing.AllocsPerRun(1000, func() {
+				permit, _ := cb.Acquire()
+				permit.Success()
+				permit, _ = cb.Acquire()
+				permit.Failure()
+				permit, _ = cb.Acquire()
+				permit.Done(errAllocations)
+			})
+			if allocations != 0 {
+				t.Fatalf("expected no allocations but got %g.", allocations)
+			}
+		})
+	}
+
+	// Rejecting executions should not allocate either.
+	t.Run("rejected", func(t *testing.T) {
+		cb := fastbreaker.New(fastbreaker.Configuration{})
+		defer cb.Stop()
+
+		for cb.State() == fastbreaker.StateClosed {
+			permit, _ := cb.Acquire()
+			permit.Failure()
+		}
+		allocations := testing.AllocsPerRun(1000, func() {
+			_, _ = cb.Acquire()
+		})
+		if allocations != 0 {
+			t.Fatalf("expected no allocations but got %g.", allocations)
+		}
+
+		cb.ForceOpen(0)
+		allocations = testing.AllocsPerRun(1000, func() {
+			_, _ = cb.Acquire()
+		})
+		if allocations != 0 {
+			t.Fatalf("expected no allocations but got %g.", allocations)
+		}
+	})
+}
+
+func BenchmarkAllow(b *testing.B) {
+	cb := fastbreaker.New(fastbreaker.Configuration{})
+	defer cb.Stop()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		feedback, _ := cb.Allow()
+		feedback(true)
+	}
+}
+
+func BenchmarkAcquire(b *testing.B) {
+	cb := fastbreaker.New(fastbreaker.Configuration{})
+	defer cb.Stop()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		permit, _ := cb.Acquire()
+		permit.Success()
+	}
+}
+
+func BenchmarkAcquireParallel(b *testing.B) {
+	for _, stripes := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("stripes=%d", stripes), func(b *testing.B) {
+			cb := fastbreaker.New(fastbreaker.Configuration{CounterStripes: stripes})
+			defer cb.Stop()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					permit, _ := cb.Acquire()
+					permit.Success()
+				}
+			})
+		})
+	}
+}
+
+func TestCounterStripes(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		CounterStripes: 4,
+		ShouldTrip:     func(executions uint64, failures uint64) bool { return false },
+		Clock:          clock,
+	})
+	defer cb.Stop()
+
+	// the counters of every stripe should be summed.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				permit, _ := cb.Acquire()
+				if j%4 == 0 {
+					permit.Failure()
+				} else {
+					permit.Success()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 800, 200)
+	assertRollingCounters(t, cb, 800, 200)
+
+	// closing the circuit should reset every stripe of the rolling window.
+	cb.ForceOpen(0)
+	cb.ClearOverride()
+	assertRollingCounters(t, cb, 0, 0)
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 800, 200)
+}
+
+func TestConcurrentExecutions(t *testing.T) {
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		BucketDuration:       fastbreaker.MinBucketDuration,
+		DurationOfBreak:      fastbreaker.MinDurationOfBreak,
+		HalfOpenMaxProbes:    2,
+		HalfOpenProbeTimeout: fastbreaker.MinDurationOfBreak,
+		SlowCallDuration:     time.Millisecond,
+	})
+	defer cb.Stop()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if feedback, err := cb.Allow(); err == nil {
+					feedback((i+j)%3 != 0)
+				}
+				cb.RollingCounters()
+			}
+		}(i)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	cb.ForceOpen(fastbreaker.MinDurationOfBreak)
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	if cb.Executions() == 0 || cb.Failures() == 0 || cb.Rejected() == 0 {
+		t.Fatalf("expected executions, failures and rejections but got %d, %d and %d.", cb.Executions(), cb.Failures(), cb.Rejected())
+	}
+}
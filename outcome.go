@@ -0,0 +1,59 @@
+package fastbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Outcome is the result of an execution as seen by the circuit breaker.
+type Outcome uint32
+
+const (
+	// OutcomeSuccess is the outcome of a successful execution.
+	OutcomeSuccess Outcome = iota
+	// OutcomeFailure is the outcome of a failed execution.
+	OutcomeFailure
+	// OutcomeIgnored is the outcome of an execution that neither succeeded nor failed, like an
+	// execution cancelled by the caller. Ignored executions are not counted and, in the half-open
+	// state, release their permit.
+	OutcomeIgnored
+	// OutcomeTimeout is the outcome of an execution that did not complete in time. Timeouts are
+	// counted as failures and, besides, as timeouts.
+	OutcomeTimeout
+)
+
+func (outcome Outcome) String() string {
+	switch outcome {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeFailure:
+		return "failure"
+	case OutcomeIgnored:
+		return "ignored"
+	case OutcomeTimeout:
+		return "timeout"
+	default:
+		return fmt.Sprintf("unknown outcome %d", outcome)
+	}
+}
+
+// A ClassifierFunc maps the error returned by an execution to its Outcome.
+type ClassifierFunc func(err error) Outcome
+
+// DefaultClassifier is the default implementation of the Classifier function.
+// It classifies a nil error as a success, an error wrapping ErrCallTimeout or
+// context.DeadlineExceeded as a timeout, an error wrapping context.Canceled as ignored, as the
+// execution was cancelled by the caller, and any other error as a failure.
+func DefaultClassifier(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrCallTimeout), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeTimeout
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnored
+	default:
+		return OutcomeFailure
+	}
+}
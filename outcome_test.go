@@ -0,0 +1,99 @@
+package fastbreaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestOutcomeString(t *testing.T) {
+	tests := map[fastbreaker.Outcome]string{
+		fastbreaker.OutcomeSuccess: "success",
+		fastbreaker.OutcomeFailure: "failure",
+		fastbreaker.OutcomeIgnored: "ignored",
+		fastbreaker.OutcomeTimeout: "timeout",
+		fastbreaker.Outcome(100):   "unknown outcome 100",
+	}
+
+	for outcome, expected := range tests {
+		if outcome.String() != expected {
+			t.Errorf("expected %q but got %q.", expected, outcome.String())
+		}
+	}
+}
+
+func TestDefaultClassifier(t *testing.T) {
+	type testSpec struct {
+		name   string
+		err    error
+		expect fastbreaker.Outcome
+	}
+
+	tests := []testSpec{
+		{"nil", nil, fastbreaker.OutcomeSuccess},
+		{"error", errors.New("boom"), fastbreaker.OutcomeFailure},
+		{"canceled", context.Canceled, fastbreaker.OutcomeIgnored},
+		{"wrapped canceled", fmt.Errorf("request: %w", context.Canceled), fastbreaker.OutcomeIgnored},
+		{"deadline exceeded", context.DeadlineExceeded, fastbreaker.OutcomeTimeout},
+		{"call timeout", fastbreaker.ErrCallTimeout, fastbreaker.OutcomeTimeout},
+		{"wrapped call timeout", fmt.Errorf("request: %w", fastbreaker.ErrCallTimeout), fastbreaker.OutcomeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := fastbreaker.DefaultClassifier(tt.err); actual != tt.expect {
+				t.Errorf("expected %s but got %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestPermitDone(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		// not found errors are successful executions.
+		Classifier: func(err error) fastbreaker.Outcome {
+			if errors.Is(err, errNotFound) {
+				return fastbreaker.OutcomeSuccess
+			}
+			return fastbreaker.DefaultClassifier(err)
+		},
+		Clock: clock,
+	})
+	defer cb.Stop()
+
+	done := func(err error) {
+		t.Helper()
+		permit, acquireErr := cb.Acquire()
+		if acquireErr != nil {
+			t.Fatalf("unexpected error %v.", acquireErr)
+		}
+		permit.Done(err)
+	}
+
+	// Errors should be classified by the classifier.
+	done(nil)
+	done(errNotFound)
+	done(context.Canceled)
+	done(errors.New("boom"))
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 3, 1)
+	assertRollingCounters(t, cb, 3, 1)
+
+	// An ignored probe should release its permit without closing the circuit.
+	tripAndWaitHalfOpen(t, cb, clock)
+	for i := 0; i < 3; i++ {
+		done(context.Canceled)
+		if cb.State() != fastbreaker.StateHalfOpen {
+			t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, cb.State())
+		}
+	}
+	done(errNotFound)
+	if cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateClosed, cb.State())
+	}
+}
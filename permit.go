@@ -0,0 +1,140 @@
+package fastbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Permit is the permission to perform an execution granted by FastBreaker.Acquire().
+// The outcome of the execution must be reported exactly once by calling Success, Failure, Ignore,
+// Done or Report.
+// Permit is a small value type, so acquiring and reporting a permit does not allocate in the
+// closed state.
+type Permit struct {
+	cb    *fastBreaker
+	state State
+	start time.Time
+	probe *halfOpenProbe
+}
+
+// Success reports the execution was successful.
+func (p Permit) Success() {
+	p.Report(OutcomeSuccess)
+}
+
+// Failure reports the execution failed.
+func (p Permit) Failure() {
+	p.Report(OutcomeFailure)
+}
+
+// Ignore reports the execution neither succeeded nor failed, like when it was cancelled by the
+// caller. The execution is not counted and, in the half-open state, its permit is released.
+func (p Permit) Ignore() {
+	p.Report(OutcomeIgnored)
+}
+
+// Done reports the outcome of the execution from the error it returned, as classified by
+// Configuration.Classifier. Done returns the reported outcome.
+func (p Permit) Done(err error) Outcome {
+	if p.cb == nil {
+		return DefaultClassifier(err)
+	}
+	outcome := p.cb.configuration.Classifier(err)
+	p.Report(outcome)
+	return outcome
+}
+
+// report reports if the execution was successful to the circuit breaker.
+func (p Permit) report(success bool) {
+	if success {
+		p.Report(OutcomeSuccess)
+	} else {
+		p.Report(OutcomeFailure)
+	}
+}
+
+// Report reports the outcome of the execution.
+func (p Permit) Report(outcome Outcome) {
+	if p.cb == nil {
+		return
+	}
+	if p.probe != nil && !p.probe.report() {
+		return
+	}
+	p.cb.handleFeedback(p.state, outcome, outcome != OutcomeIgnored && p.cb.isSlow(p.start))
+}
+
+// halfOpenProbe tracks an execution allowed in the half-open state. If the execution is not
+// reported within HalfOpenProbeTimeout, it is considered failed.
+type halfOpenProbe struct {
+	cb         *fastBreaker
+	generation uint64
+	reported   atomic.Bool
+	timer      Timer
+}
+
+func newHalfOpenProbe(cb *fastBreaker) *halfOpenProbe {
+	probe := &halfOpenProbe{
+		cb:         cb,
+		generation: cb.halfOpenGeneration.Load(),
+	}
+	probe.timer = cb.configuration.Clock.AfterFunc(cb.configuration.HalfOpenProbeTimeout, probe.timeout)
+	cb.halfOpenProbes.add(probe)
+	return probe
+}
+
+// report marks the probe as reported. It returns false if the probe was already reported, timed out
+// or was allowed in a previous half-open state.
+func (probe *halfOpenProbe) report() bool {
+	if !probe.reported.CompareAndSwap(false, true) {
+		return false
+	}
+	probe.timer.Stop()
+	probe.cb.halfOpenProbes.remove(probe)
+	// Ignore feedback of executions allowed in a previous half-open state.
+	return probe.cb.halfOpenGeneration.Load() == probe.generation
+}
+
+// timeout opens the circuit again if the probe was not reported and the circuit is still half-open.
+func (probe *halfOpenProbe) timeout() {
+	if !probe.reported.CompareAndSwap(false, true) {
+		return
+	}
+	probe.cb.halfOpenProbes.remove(probe)
+	if probe.cb.halfOpenGeneration.Load() == probe.generation && probe.cb.tripFrom(StateHalfOpen, ReasonProbeTimedOut) {
+		probe.cb.streak(0, true)
+	}
+}
+
+// halfOpenProbes holds the probes waiting for their outcome.
+type halfOpenProbes struct {
+	mutex  sync.Mutex
+	probes map[*halfOpenProbe]struct{}
+}
+
+func (probes *halfOpenProbes) add(probe *halfOpenProbe) {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+	if probes.probes == nil {
+		probes.probes = make(map[*halfOpenProbe]struct{})
+	}
+	probes.probes[probe] = struct{}{}
+}
+
+func (probes *halfOpenProbes) remove(probe *halfOpenProbe) {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+	delete(probes.probes, probe)
+}
+
+// stop stops the timers of the probes waiting for their outcome, as they can no longer time out once
+// the circuit leaves the half-open state. Their late outcome is still ignored by report.
+func (probes *halfOpenProbes) stop() {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+	for probe := range probes.probes {
+		probe.timer.Stop()
+		delete(probes.probes, probe)
+	}
+}
@@ -0,0 +1,86 @@
+package fastbreaker
+
+const (
+	// DefaultFailureRateThreshold is the fraction of failed executions that trips the circuit with the
+	// DefaultTripPolicy. Value = 0.5.
+	DefaultFailureRateThreshold = 0.5
+	// DefaultMinExecutions is the number of executions in the rolling window required to trip the
+	// circuit with the DefaultTripPolicy. Value = 20.
+	DefaultMinExecutions = 20
+)
+
+// DefaultTripPolicy trips the circuit when there has been at least DefaultMinExecutions executions
+// and at least DefaultFailureRateThreshold of the executions failed.
+var DefaultTripPolicy = FailureRate(DefaultFailureRateThreshold, DefaultMinExecutions)
+
+// A TripPolicyFunc is a function implementing TripPolicy.
+type TripPolicyFunc func(stats Stats) bool
+
+// ShouldTrip implements TripPolicy calling f.
+func (f TripPolicyFunc) ShouldTrip(stats Stats) bool {
+	return f(stats)
+}
+
+// FailureRate returns a TripPolicy that trips the circuit when there has been at least minExecutions
+// executions in the rolling window and the fraction of them that failed is at least threshold.
+func FailureRate(threshold float64, minExecutions uint64) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		return stats.Executions >= minExecutions && stats.FailureRate() >= threshold
+	})
+}
+
+// SlowCallRate returns a TripPolicy that trips the circuit when there has been at least minExecutions
+// executions in the rolling window and the fraction of them that were slow is at least threshold.
+func SlowCallRate(threshold float64, minExecutions uint64) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		return stats.Executions >= minExecutions && stats.SlowCallRate() >= threshold
+	})
+}
+
+// FailureCount returns a TripPolicy that trips the circuit when there has been at least n failures
+// in the rolling window.
+func FailureCount(n uint64) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		return stats.Failures >= n
+	})
+}
+
+// ConsecutiveFailures returns a TripPolicy that trips the circuit when at least n executions failed
+// in a row, regardless of the number of executions in the rolling window.
+func ConsecutiveFailures(n uint64) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		return stats.ConsecutiveFailures >= n
+	})
+}
+
+// And returns a TripPolicy that trips the circuit when all the policies trip it.
+// And without policies never trips the circuit.
+func And(policies ...TripPolicy) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		for _, policy := range policies {
+			if !policy.ShouldTrip(stats) {
+				return false
+			}
+		}
+		return len(policies) > 0
+	})
+}
+
+// Or returns a TripPolicy that trips the circuit when any of the policies trips it.
+func Or(policies ...TripPolicy) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		for _, policy := range policies {
+			if policy.ShouldTrip(stats) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not returns a TripPolicy that trips the circuit when policy does not trip it.
+func Not(policy TripPolicy) TripPolicy {
+	return TripPolicyFunc(func(stats Stats) bool {
+		return !policy.ShouldTrip(stats)
+	})
+}
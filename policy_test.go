@@ -0,0 +1,73 @@
+package fastbreaker_test
+
+import (
+	"testing"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestTripPolicies(t *testing.T) {
+	type testSpec struct {
+		name   string
+		policy fastbreaker.TripPolicy
+		stats  fastbreaker.Stats
+		expect bool
+	}
+
+	tests := []testSpec{
+		{"default below min executions", fastbreaker.DefaultTripPolicy, fastbreaker.Stats{Executions: 19, Failures: 19}, false},
+		{"default below threshold", fastbreaker.DefaultTripPolicy, fastbreaker.Stats{Executions: 20, Failures: 9}, false},
+		{"default at threshold", fastbreaker.DefaultTripPolicy, fastbreaker.Stats{Executions: 20, Failures: 10}, true},
+		{"failure rate", fastbreaker.FailureRate(0.2, 5), fastbreaker.Stats{Executions: 5, Failures: 1}, true},
+		{"failure rate below min executions", fastbreaker.FailureRate(0.2, 5), fastbreaker.Stats{Executions: 4, Failures: 4}, false},
+		{"slow call rate", fastbreaker.SlowCallRate(0.5, 10), fastbreaker.Stats{Executions: 10, SlowCalls: 5}, true},
+		{"slow call rate below threshold", fastbreaker.SlowCallRate(0.5, 10), fastbreaker.Stats{Executions: 10, Failures: 10, SlowCalls: 4}, false},
+		{"failure count", fastbreaker.FailureCount(3), fastbreaker.Stats{Executions: 1000, Failures: 3}, true},
+		{"failure count below", fastbreaker.FailureCount(3), fastbreaker.Stats{Executions: 3, Failures: 2}, false},
+		{"consecutive failures", fastbreaker.ConsecutiveFailures(5), fastbreaker.Stats{Executions: 5, Failures: 5, ConsecutiveFailures: 5}, true},
+		{"consecutive failures below", fastbreaker.ConsecutiveFailures(5), fastbreaker.Stats{Executions: 10, Failures: 10, ConsecutiveFailures: 4}, false},
+		{"and", fastbreaker.And(fastbreaker.FailureCount(1), fastbreaker.ConsecutiveFailures(1)), fastbreaker.Stats{Failures: 1, ConsecutiveFailures: 1}, true},
+		{"and partial", fastbreaker.And(fastbreaker.FailureCount(1), fastbreaker.ConsecutiveFailures(2)), fastbreaker.Stats{Failures: 1, ConsecutiveFailures: 1}, false},
+		{"and empty", fastbreaker.And(), fastbreaker.Stats{}, false},
+		{"or", fastbreaker.Or(fastbreaker.FailureCount(2), fastbreaker.ConsecutiveFailures(1)), fastbreaker.Stats{Failures: 1, ConsecutiveFailures: 1}, true},
+		{"or none", fastbreaker.Or(fastbreaker.FailureCount(2), fastbreaker.ConsecutiveFailures(2)), fastbreaker.Stats{Failures: 1, ConsecutiveFailures: 1}, false},
+		{"or empty", fastbreaker.Or(), fastbreaker.Stats{}, false},
+		{"not", fastbreaker.Not(fastbreaker.FailureCount(2)), fastbreaker.Stats{Failures: 1}, true},
+		{"should trip func", fastbreaker.ShouldTripFunc(fastbreaker.DefaultShouldTrip), fastbreaker.Stats{Executions: 20, Failures: 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.policy.ShouldTrip(tt.stats); actual != tt.expect {
+				t.Errorf("expected ShouldTrip %t but was %t", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestComposedTripPolicy(t *testing.T) {
+	clock := newFakeClock()
+	cb := fastbreaker.New(fastbreaker.Configuration{
+		// trip after 5 failures in a row or when half the executions failed.
+		TripPolicy: fastbreaker.Or(
+			fastbreaker.ConsecutiveFailures(5),
+			fastbreaker.FailureRate(0.5, 100),
+		),
+		Clock: clock,
+	})
+	defer cb.Stop()
+
+	// interleaved failures should not trip the circuit below the minimum executions.
+	for i := 0; i < 20; i++ {
+		allowAndAssert(t, cb, true)(i%2 != 0)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 20, 10)
+
+	// 5 failures in a row should trip the circuit.
+	for i := 0; i < 4; i++ {
+		allowAndAssert(t, cb, true)(false)
+	}
+	assertStateAndCounters(t, cb, fastbreaker.StateClosed, 24, 14)
+	allowAndAssert(t, cb, true)(false)
+	assertStateAndCounters(t, cb, fastbreaker.StateOpen, 25, 15)
+}
@@ -19,7 +19,19 @@ const (
 
 	// OpenStateMetricName is the suffix of the open metric.
 	OpenStateMetricName = "open"
-	openStateMetricHelp = "One if the circuit is not in the closed state."
+	openStateMetricHelp = "One if the circuit is not in the closed or forced-closed state."
+
+	// SlowCallsMetricName is the suffix of the slow calls metric.
+	SlowCallsMetricName = "slow_calls_total"
+	slowCallsMetricHelp = "Number of executions that took at least the slow call duration."
+
+	// TimeoutsMetricName is the suffix of the timeouts metric.
+	TimeoutsMetricName = "timeouts_total"
+	timeoutsMetricHelp = "Number of executions that timed out. Timeouts are counted as failures too."
+
+	// FallbacksMetricName is the suffix of the fallbacks metric.
+	FallbacksMetricName = "fallbacks_total"
+	fallbacksMetricHelp = "Number of fallbacks called instead of rejected or failed executions."
 
 	// SlidingFailureRateMetricName is the suffix of the sliding failure rate metric.
 	SlidingFailureRateMetricName = "sliding_failure_rate"
@@ -57,6 +69,9 @@ func RegisterMetricsWithFactory(circuitBreakerName string, cb fastbreaker.FastBr
 	circuitBreakerOpen(circuitBreakerName, cb, factory)
 	slidingFailureRate(circuitBreakerName, cb, factory)
 	executionsCounters(circuitBreakerName, cb, factory)
+	slowCallsCounter(circuitBreakerName, cb, factory)
+	fallbacksCounters(circuitBreakerName, cb, factory)
+	timeoutsCounter(circuitBreakerName, cb, factory)
 
 	return cb, nil
 }
@@ -70,7 +85,8 @@ func circuitBreakerOpen(circuitBreakerName string, cb fastbreaker.FastBreaker, f
 			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName},
 		},
 		func() float64 {
-			if cb.State() == fastbreaker.StateClosed {
+			switch cb.State() {
+			case fastbreaker.StateClosed, fastbreaker.StateForcedClosed:
 				return 0.0
 			}
 			return 1.0
@@ -132,4 +148,72 @@ func executionsCounters(circuitBreakerName string, cb fastbreaker.FastBreaker, f
 			return float64(cb.Rejected())
 		},
 	)
+
+	factory.NewCounterFunc(
+		prom.CounterOpts{
+			Namespace:   MetricsNamespace,
+			Name:        ExecutionsMetricName,
+			Help:        executionsMetricHelp,
+			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName, ExecutionStatusLabel: "ignored"},
+		},
+		func() float64 {
+			return float64(cb.Ignored())
+		},
+	)
+}
+
+func slowCallsCounter(circuitBreakerName string, cb fastbreaker.FastBreaker, factory promauto.Factory) {
+	factory.NewCounterFunc(
+		prom.CounterOpts{
+			Namespace:   MetricsNamespace,
+			Name:        SlowCallsMetricName,
+			Help:        slowCallsMetricHelp,
+			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName},
+		},
+		func() float64 {
+			return float64(cb.SlowCalls())
+		},
+	)
+}
+
+func fallbacksCounters(circuitBreakerName string, cb fastbreaker.FastBreaker, factory promauto.Factory) {
+	factory.NewCounterFunc(
+		prom.CounterOpts{
+			Namespace:   MetricsNamespace,
+			Name:        FallbacksMetricName,
+			Help:        fallbacksMetricHelp,
+			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName, ExecutionStatusLabel: "success"},
+		},
+		func() float64 {
+			successes, _ := cb.FallbackCounters()
+			return float64(successes)
+		},
+	)
+
+	factory.NewCounterFunc(
+		prom.CounterOpts{
+			Namespace:   MetricsNamespace,
+			Name:        FallbacksMetricName,
+			Help:        fallbacksMetricHelp,
+			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName, ExecutionStatusLabel: "failure"},
+		},
+		func() float64 {
+			_, failures := cb.FallbackCounters()
+			return float64(failures)
+		},
+	)
+}
+
+func timeoutsCounter(circuitBreakerName string, cb fastbreaker.FastBreaker, factory promauto.Factory) {
+	factory.NewCounterFunc(
+		prom.CounterOpts{
+			Namespace:   MetricsNamespace,
+			Name:        TimeoutsMetricName,
+			Help:        timeoutsMetricHelp,
+			ConstLabels: prom.Labels{CircuitBreakerNameLabel: circuitBreakerName},
+		},
+		func() float64 {
+			return float64(cb.Timeouts())
+		},
+	)
 }
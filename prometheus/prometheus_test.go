@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bluekiri/fastbreaker"
 	"github.com/bluekiri/fastbreaker/prometheus"
@@ -12,10 +13,11 @@ import (
 )
 
 func FuzzRegisterMetrics(f *testing.F) {
-	f.Add("test", uint32(fastbreaker.StateClosed), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0))
-	f.Add("test2", uint32(fastbreaker.StateOpen), uint64(100), uint64(75), uint64(25), uint64(100), uint64(75))
+	f.Add("test", uint32(fastbreaker.StateClosed), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0))
+	f.Add("test2", uint32(fastbreaker.StateOpen), uint64(100), uint64(75), uint64(25), uint64(100), uint64(75), uint64(10), uint64(5), uint64(20), uint64(5), uint64(15))
+	f.Add("test3", uint32(fastbreaker.StateForcedClosed), uint64(100), uint64(75), uint64(0), uint64(100), uint64(75), uint64(0), uint64(0), uint64(0), uint64(0), uint64(0))
 
-	f.Fuzz(func(t *testing.T, cbName string, state uint32, executions uint64, failures uint64, rejected uint64, rollingExecutions uint64, rollingFailures uint64) {
+	f.Fuzz(func(t *testing.T, cbName string, state uint32, executions uint64, failures uint64, rejected uint64, rollingExecutions uint64, rollingFailures uint64, slowCalls uint64, ignored uint64, fallbackSuccesses uint64, fallbackFailures uint64, timeouts uint64) {
 		registry := prom.NewRegistry()
 
 		// Register the circuit breaker.
@@ -28,6 +30,11 @@ func FuzzRegisterMetrics(f *testing.F) {
 				rejected:          rejected,
 				rollingExecutions: rollingExecutions,
 				rollingFailures:   rollingFailures,
+				slowCalls:         slowCalls,
+				ignored:           ignored,
+				fallbackSuccesses: fallbackSuccesses,
+				fallbackFailures:  fallbackFailures,
+				timeouts:          timeouts,
 			},
 			registry)
 
@@ -80,10 +87,60 @@ func FuzzRegisterMetrics(f *testing.F) {
 						assertMetric(t, metricFamily, metric.GetCounter().GetValue(), float64(cb.Failures()))
 					case "rejected":
 						assertMetric(t, metricFamily, metric.GetCounter().GetValue(), float64(cb.Rejected()))
+					case "ignored":
+						assertMetric(t, metricFamily, metric.GetCounter().GetValue(), float64(cb.Ignored()))
 					default:
 						t.Errorf("unexpected metric %s", metric.String())
 					}
 				}
+			case prom.BuildFQName(prometheus.MetricsNamespace, "", prometheus.FallbacksMetricName):
+				// The metric should be a counter
+				if metricFamily.GetType() != client_model.MetricType_COUNTER {
+					t.Errorf("%s should be a counter", metricFamily.GetName())
+				}
+
+				// The metric should have the CircuitBreakerName label
+				assertCircuitBreakerLabel(t, metricFamily, cbName)
+
+				expectedSuccesses, expectedFailures := cb.FallbackCounters()
+				for _, metric := range metricFamily.Metric {
+					// The metric should have the ExecutionStatusLabel label
+					statusLabelValue, err := getLabelValue(metric, prometheus.ExecutionStatusLabel)
+					if err != nil {
+						t.Error(err.Error())
+					}
+					// Validate the metrics value
+					switch statusLabelValue {
+					case "success":
+						assertMetric(t, metricFamily, metric.GetCounter().GetValue(), float64(expectedSuccesses))
+					case "failure":
+						assertMetric(t, metricFamily, metric.GetCounter().GetValue(), float64(expectedFailures))
+					default:
+						t.Errorf("unexpected metric %s", metric.String())
+					}
+				}
+			case prom.BuildFQName(prometheus.MetricsNamespace, "", prometheus.TimeoutsMetricName):
+				// The metric should be a counter
+				if metricFamily.GetType() != client_model.MetricType_COUNTER {
+					t.Errorf("%s should be a counter", metricFamily.GetName())
+				}
+
+				// The metric should have the CircuitBreakerName label
+				assertCircuitBreakerLabel(t, metricFamily, cbName)
+
+				// Validate the metrics value
+				assertMetric(t, metricFamily, metricFamily.Metric[0].GetCounter().GetValue(), float64(cb.Timeouts()))
+			case prom.BuildFQName(prometheus.MetricsNamespace, "", prometheus.SlowCallsMetricName):
+				// The metric should be a counter
+				if metricFamily.GetType() != client_model.MetricType_COUNTER {
+					t.Errorf("%s should be a counter", metricFamily.GetName())
+				}
+
+				// The metric should have the CircuitBreakerName label
+				assertCircuitBreakerLabel(t, metricFamily, cbName)
+
+				// Validate the metrics value
+				assertMetric(t, metricFamily, metricFamily.Metric[0].GetCounter().GetValue(), float64(cb.SlowCalls()))
 			case prom.BuildFQName(prometheus.MetricsNamespace, "", prometheus.OpenStateMetricName):
 				// The metric should be a gauge
 				if metricFamily.GetType() != client_model.MetricType_GAUGE {
@@ -95,7 +152,7 @@ func FuzzRegisterMetrics(f *testing.F) {
 
 				// Validate the metrics value
 				expectedOpenCircuits := 0.0
-				if cb.State() != fastbreaker.StateClosed {
+				if cb.State() != fastbreaker.StateClosed && cb.State() != fastbreaker.StateForcedClosed {
 					expectedOpenCircuits = 1.0
 				}
 				assertMetric(t, metricFamily, metricFamily.Metric[0].GetGauge().GetValue(), expectedOpenCircuits)
@@ -163,6 +220,16 @@ type mockCircuitBreaker struct {
 	rejected          uint64
 	rollingExecutions uint64
 	rollingFailures   uint64
+	slowCalls         uint64
+	ignored           uint64
+	fallbackSuccesses uint64
+	fallbackFailures  uint64
+	timeouts          uint64
+}
+
+// Start implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Start() {
+	panic("unimplemented")
 }
 
 // Stop implements fastbreaker.FastBreaker
@@ -170,11 +237,51 @@ func (m *mockCircuitBreaker) Stop() {
 	panic("unimplemented")
 }
 
+// Restart implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Restart() {
+	panic("unimplemented")
+}
+
+// ForceOpen implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) ForceOpen(ttl time.Duration) error {
+	panic("unimplemented")
+}
+
+// ForceClose implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) ForceClose(ttl time.Duration) error {
+	panic("unimplemented")
+}
+
+// Isolate implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Isolate(ttl time.Duration) error {
+	panic("unimplemented")
+}
+
+// ClearOverride implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) ClearOverride() error {
+	panic("unimplemented")
+}
+
+// Subscribe implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Subscribe(listener func(fastbreaker.StateChange)) func() {
+	panic("unimplemented")
+}
+
+// Notify implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Notify(ch chan<- fastbreaker.StateChange) func() {
+	panic("unimplemented")
+}
+
 // Allow implements fastbreaker.FastBreaker
 func (m *mockCircuitBreaker) Allow() (func(bool), error) {
 	panic("unimplemented")
 }
 
+// Acquire implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Acquire() (fastbreaker.Permit, error) {
+	panic("unimplemented")
+}
+
 // Configuration implements fastbreaker.FastBreaker
 func (m *mockCircuitBreaker) Configuration() fastbreaker.Configuration {
 	panic("unimplemented")
@@ -200,7 +307,52 @@ func (m *mockCircuitBreaker) Rejected() uint64 {
 	return m.rejected
 }
 
+// SlowCalls implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) SlowCalls() uint64 {
+	return m.slowCalls
+}
+
+// RollingSlowCalls implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) RollingSlowCalls() uint64 {
+	panic("unimplemented")
+}
+
 // RollingCounters implements fastbreaker.FastBreaker
 func (m *mockCircuitBreaker) RollingCounters() (uint64, uint64) {
 	return m.rollingExecutions, m.rollingFailures
 }
+
+// Stats implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Stats() fastbreaker.Stats {
+	panic("unimplemented")
+}
+
+// ConsecutiveFailures implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) ConsecutiveFailures() uint64 {
+	panic("unimplemented")
+}
+
+// ConsecutiveSuccesses implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) ConsecutiveSuccesses() uint64 {
+	panic("unimplemented")
+}
+
+// Ignored implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Ignored() uint64 {
+	return m.ignored
+}
+
+// FallbackCounters implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) FallbackCounters() (uint64, uint64) {
+	return m.fallbackSuccesses, m.fallbackFailures
+}
+
+// RecordFallback implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) RecordFallback(success bool) {
+	panic("unimplemented")
+}
+
+// Timeouts implements fastbreaker.FastBreaker
+func (m *mockCircuitBreaker) Timeouts() uint64 {
+	return m.timeouts
+}
@@ -0,0 +1,358 @@
+package fastbreaker
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRegistryStopped is the error returned by Registry.Get() when the registry is stopped.
+var ErrRegistryStopped = errors.New("circuit breaker registry is stopped")
+
+// EvictionReason is the cause of the eviction of a circuit breaker from a Registry.
+type EvictionReason uint32
+
+const (
+	// EvictionCapacity is the reason of the eviction of the least recently used circuit breaker when
+	// the registry holds more than MaxSize circuit breakers.
+	EvictionCapacity EvictionReason = iota
+	// EvictionIdle is the reason of the eviction of a circuit breaker neither requested nor executing
+	// for IdleTimeout.
+	EvictionIdle
+)
+
+func (reason EvictionReason) String() string {
+	switch reason {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("unknown eviction reason %d", reason)
+	}
+}
+
+// RegistryConfiguration is a struct used to configure a Registry.
+type RegistryConfiguration struct {
+	// Template is the configuration of the circuit breakers created by the registry. The Name of every
+	// circuit breaker is its key. The Clock of the template also measures the idle time of the circuit
+	// breakers.
+	Template Configuration
+	// Override, if not nil, is called with the key and the template configuration of every circuit
+	// breaker before it is created, and returns the configuration used to create it.
+	Override func(key string, configuration Configuration) Configuration
+	// MaxSize, if positive, is the number of circuit breakers above which the least recently used ones
+	// are evicted. A circuit breaker is used when it is requested with Get or when it executes.
+	MaxSize int
+	// IdleTimeout, if positive, is the time after which a circuit breaker neither requested nor
+	// executing is evicted. The circuit breakers are checked every IdleTimeout, so they are evicted
+	// after being idle between IdleTimeout and twice IdleTimeout.
+	IdleTimeout time.Duration
+	// OnEvict, if not nil, is called with every evicted circuit breaker after stopping it. It may be
+	// called concurrently from the goroutines calling Get and from the goroutine checking the idle
+	// circuit breakers.
+	OnEvict func(key string, cb FastBreaker, reason EvictionReason)
+}
+
+// Registry holds a circuit breaker per key, like a circuit breaker per downstream host. The circuit
+// breakers are created the first time their key is requested. A Registry is safe for concurrent use.
+// The circuit breakers are evicted and stopped when the registry exceeds MaxSize or when they are
+// idle for IdleTimeout, but only while they are closed: open, half-open and pinned circuit breakers
+// are never evicted, even if the registry exceeds MaxSize. A circuit breaker is not idle while it is
+// executing, even if the caller keeps it instead of calling Get for every execution.
+type Registry struct {
+	configuration RegistryConfiguration
+	clock         Clock
+	mutex         sync.RWMutex
+	// entries holds the circuit breakers in the order they are checked for eviction by MaxSize, from the
+	// back. The ones requested or executing since they were last checked are moved to the front.
+	entries     *list.List
+	breakers    map[string]*list.Element
+	stopped     bool
+	sweepTicker Ticker
+}
+
+// registryEntry is a circuit breaker held by a Registry.
+type registryEntry struct {
+	key string
+	cb  *fastBreaker
+	// used is set by Get and cleared when the circuit breaker is checked for eviction by MaxSize.
+	used atomic.Bool
+	// lastUsed is the last time the circuit breaker was requested or seen executing in nanoseconds
+	// since the Unix epoch.
+	lastUsed atomic.Int64
+	// activity is the activity of the circuit breaker when it was last refreshed. It is guarded by
+	// the mutex.
+	activity uint64
+}
+
+// NewRegistry creates a new Registry with the passed RegistryConfiguration.
+// NewRegistry panics if the template configuration is not valid. See Configuration.Validate().
+func NewRegistry(configuration RegistryConfiguration) *Registry {
+	if err := configuration.Template.Validate(); err != nil {
+		panic(err)
+	}
+
+	registry := &Registry{
+		configuration: configuration,
+		clock:         configuration.Template.Clock,
+		entries:       list.New(),
+		breakers:      make(map[string]*list.Element),
+	}
+	if registry.clock == nil {
+		registry.clock = RealClock{}
+	}
+
+	// Only idle circuit breakers need to be checked periodically.
+	if configuration.IdleTimeout > 0 {
+		registry.sweepTicker = registry.clock.NewTicker(configuration.IdleTimeout, registry.evictIdle)
+	}
+	return registry
+}
+
+// Get returns the circuit breaker of key, creating it if it does not exist yet or if it was stopped.
+// Returns ErrRegistryStopped if the registry is stopped, or an error wrapping ErrInvalidConfiguration
+// if Override returns a configuration that is not valid.
+func (registry *Registry) Get(key string) (FastBreaker, error) {
+	for {
+		registry.mutex.RLock()
+		element, ok := registry.breakers[key]
+		stopped := registry.stopped
+		registry.mutex.RUnlock()
+		if !ok {
+			if stopped {
+				return nil, ErrRegistryStopped
+			}
+			cb, existing, err := registry.create(key)
+			if err != nil {
+				return nil, err
+			}
+			if existing == nil {
+				return cb, nil
+			}
+			element = existing
+		}
+		if cb := registry.use(key, element); cb != nil {
+			return cb, nil
+		}
+	}
+}
+
+// create creates the circuit breaker of key. If another goroutine created it meanwhile, create returns
+// the element holding that circuit breaker instead.
+func (registry *Registry) create(key string) (*fastBreaker, *list.Element, error) {
+	// Create the circuit breaker without holding the mutex, as Override may use the registry.
+	configuration := registry.configuration.Template
+	configuration.Name = key
+	if registry.configuration.Override != nil {
+		configuration = registry.configuration.Override(key, configuration)
+	}
+	if err := configuration.Validate(); err != nil {
+		return nil, nil, err
+	}
+	entry := &registryEntry{key: key, cb: newFastBreaker(configuration)}
+	entry.lastUsed.Store(registry.clock.Now().UnixNano())
+
+	registry.mutex.Lock()
+	// Another goroutine may have created the circuit breaker or stopped the registry meanwhile.
+	if registry.stopped {
+		registry.mutex.Unlock()
+		entry.cb.Stop()
+		return nil, nil, ErrRegistryStopped
+	}
+	if element, ok := registry.breakers[key]; ok {
+		registry.mutex.Unlock()
+		entry.cb.Stop()
+		return nil, element, nil
+	}
+	created := registry.entries.PushFront(entry)
+	registry.breakers[key] = created
+	evicted := registry.evictCapacity(created)
+	registry.mutex.Unlock()
+
+	registry.notifyEvicted(evicted, EvictionCapacity)
+	return entry.cb, nil, nil
+}
+
+// use records that the circuit breaker held in element was requested and returns it, or nil if it was
+// evicted or stopped meanwhile and the registry needs another one for key.
+func (registry *Registry) use(key string, element *list.Element) *fastBreaker {
+	entry := element.Value.(*registryEntry)
+	registry.touch(entry)
+
+	// The circuit breaker may have been evicted before it was touched.
+	registry.mutex.RLock()
+	held := registry.breakers[key] == element
+	registry.mutex.RUnlock()
+	if !held {
+		return nil
+	}
+	if entry.cb.State() != StateStopped {
+		return entry.cb
+	}
+
+	// The circuit breaker was stopped by its caller, so replace it.
+	registry.mutex.Lock()
+	if registry.breakers[key] == element {
+		registry.remove(element)
+	}
+	registry.mutex.Unlock()
+	return nil
+}
+
+// Keys returns the sorted keys of the circuit breakers in the registry.
+func (registry *Registry) Keys() []string {
+	registry.mutex.RLock()
+	keys := make([]string, 0, len(registry.breakers))
+	for key := range registry.breakers {
+		keys = append(keys, key)
+	}
+	registry.mutex.RUnlock()
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of circuit breakers in the registry.
+func (registry *Registry) Len() int {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return len(registry.breakers)
+}
+
+// Remove stops the circuit breaker of key and removes it from the registry. The next Get creates a
+// new circuit breaker for key. Returns false if the registry does not hold a circuit breaker for key.
+func (registry *Registry) Remove(key string) bool {
+	registry.mutex.Lock()
+	element, ok := registry.breakers[key]
+	if ok {
+		registry.remove(element)
+	}
+	registry.mutex.Unlock()
+
+	if ok {
+		element.Value.(*registryEntry).cb.Stop()
+	}
+	return ok
+}
+
+// Stop stops and removes every circuit breaker in the registry. Get returns ErrRegistryStopped
+// afterwards. It does nothing if the registry is already stopped.
+func (registry *Registry) Stop() {
+	// Stop the ticker before taking the mutex, as it waits for evictIdle to return.
+	if registry.sweepTicker != nil {
+		registry.sweepTicker.Stop()
+	}
+
+	registry.mutex.Lock()
+	entries := registry.entries
+	registry.entries = list.New()
+	registry.breakers = make(map[string]*list.Element)
+	registry.stopped = true
+	registry.mutex.Unlock()
+
+	for element := entries.Front(); element != nil; element = element.Next() {
+		element.Value.(*registryEntry).cb.Stop()
+	}
+}
+
+// touch records that the circuit breaker of entry was requested.
+func (registry *Registry) touch(entry *registryEntry) {
+	if registry.configuration.MaxSize > 0 && !entry.used.Load() {
+		// Avoid writing the shared flag on every request.
+		entry.used.Store(true)
+	}
+	if registry.configuration.IdleTimeout > 0 {
+		entry.lastUsed.Store(registry.clock.Now().UnixNano())
+	}
+}
+
+// evictCapacity evicts circuit breakers from the back of entries until the registry holds MaxSize.
+// The circuit breakers requested or executing since they were last checked get a second chance and
+// are moved to the front, like the ones that can not be evicted. created is never evicted.
+// It must be called with the mutex held.
+func (registry *Registry) evictCapacity(created *list.Element) []*registryEntry {
+	if registry.configuration.MaxSize <= 0 {
+		return nil
+	}
+
+	now := registry.clock.Now()
+	var evicted []*registryEntry
+	// Check every circuit breaker at most twice, so the second time none has a second chance.
+	for checks := 2 * registry.entries.Len(); checks > 0 && registry.entries.Len() > registry.configuration.MaxSize; checks-- {
+		element := registry.entries.Back()
+		entry := element.Value.(*registryEntry)
+		if executed := entry.refresh(now); entry.used.Swap(false) || executed || element == created || !entry.evict() {
+			registry.entries.MoveToFront(element)
+			continue
+		}
+		registry.remove(element)
+		evicted = append(evicted, entry)
+	}
+	return evicted
+}
+
+// evictIdle evicts the closed circuit breakers neither requested nor executing for IdleTimeout.
+func (registry *Registry) evictIdle() {
+	now := registry.clock.Now()
+	deadline := now.Add(-registry.configuration.IdleTimeout).UnixNano()
+
+	var evicted []*registryEntry
+	registry.mutex.Lock()
+	for element := registry.entries.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*registryEntry)
+		entry.refresh(now)
+		if entry.lastUsed.Load() <= deadline && entry.evict() {
+			registry.remove(element)
+			evicted = append(evicted, entry)
+		}
+		element = next
+	}
+	registry.mutex.Unlock()
+
+	registry.notifyEvicted(evicted, EvictionIdle)
+}
+
+// remove removes the circuit breaker held in element from the registry. It must be called with the
+// mutex held.
+func (registry *Registry) remove(element *list.Element) {
+	registry.entries.Remove(element)
+	delete(registry.breakers, element.Value.(*registryEntry).key)
+}
+
+// notifyEvicted calls OnEvict with the evicted circuit breakers. It must be called without the mutex
+// held.
+func (registry *Registry) notifyEvicted(evicted []*registryEntry, reason EvictionReason) {
+	if registry.configuration.OnEvict == nil {
+		return
+	}
+	for _, entry := range evicted {
+		registry.configuration.OnEvict(entry.key, entry.cb, reason)
+	}
+}
+
+// refresh marks the circuit breaker of entry as used at now if it executed since the last refresh, as
+// the callers may keep using it without calling Get. It must be called with the mutex held.
+// Returns true if the circuit breaker executed.
+func (entry *registryEntry) refresh(now time.Time) bool {
+	activity := entry.cb.activity()
+	if activity == entry.activity {
+		return false
+	}
+	entry.activity = activity
+	entry.lastUsed.Store(now.UnixNano())
+	return true
+}
+
+// evict stops the circuit breaker of entry if it can be evicted: closed circuit breakers can be
+// recreated without losing anything relevant, while open, half-open and pinned ones would forget they
+// are rejecting executions. Stopped circuit breakers are evicted too.
+// Returns true if the circuit breaker can be removed from the registry.
+func (entry *registryEntry) evict() bool {
+	return entry.cb.stopIfClosed() || entry.cb.State() == StateStopped
+}
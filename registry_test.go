@@ -0,0 +1,409 @@
+package fastbreaker_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestRegistry(t *testing.T) {
+	clock := newFakeClock()
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: clock},
+		Override: func(key string, configuration fastbreaker.Configuration) fastbreaker.Configuration {
+			if key == "slow.example.com" {
+				configuration.DurationOfBreak = time.Minute
+			}
+			return configuration
+		},
+	})
+	defer registry.Stop()
+
+	// The circuit breakers should be created once per key and named by their key.
+	cb := getAndAssert(t, registry, "fast.example.com")
+	if again := getAndAssert(t, registry, "fast.example.com"); again != cb {
+		t.Fatal("expected the same circuit breaker for the same key.")
+	}
+	if cb.Configuration().Name != "fast.example.com" {
+		t.Fatalf("expected the circuit breaker to be named %q but got %q.", "fast.example.com", cb.Configuration().Name)
+	}
+	if cb.Configuration().DurationOfBreak != fastbreaker.DefaultDurationOfBreak {
+		t.Fatalf("expected the template DurationOfBreak but got %s.", cb.Configuration().DurationOfBreak)
+	}
+
+	// The overrides should only apply to their key.
+	slow := getAndAssert(t, registry, "slow.example.com")
+	if slow.Configuration().DurationOfBreak != time.Minute {
+		t.Fatalf("expected the overridden DurationOfBreak but got %s.", slow.Configuration().DurationOfBreak)
+	}
+	if keys := registry.Keys(); !reflect.DeepEqual(keys, []string{"fast.example.com", "slow.example.com"}) {
+		t.Fatalf("unexpected keys %v.", keys)
+	}
+
+	// Removed circuit breakers should be stopped and replaced on the next Get.
+	if !registry.Remove("fast.example.com") {
+		t.Fatal("expected the circuit breaker to be removed.")
+	}
+	if registry.Remove("fast.example.com") {
+		t.Fatal("expected the circuit breaker to be already removed.")
+	}
+	if cb.State() != fastbreaker.StateStopped {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateStopped, cb.State())
+	}
+	if again := getAndAssert(t, registry, "fast.example.com"); again == cb {
+		t.Fatal("expected a new circuit breaker for a removed key.")
+	}
+
+	// Stopping the registry should stop every circuit breaker.
+	registry.Stop()
+	if slow.State() != fastbreaker.StateStopped {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateStopped, slow.State())
+	}
+	if registry.Len() != 0 {
+		t.Fatalf("expected an empty registry but got %d circuit breakers.", registry.Len())
+	}
+	if _, err := registry.Get("fast.example.com"); err != fastbreaker.ErrRegistryStopped {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrRegistryStopped, err)
+	}
+}
+
+func TestRegistryInvalidConfiguration(t *testing.T) {
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Override: func(key string, configuration fastbreaker.Configuration) fastbreaker.Configuration {
+			configuration.BreakJitter = 2
+			return configuration
+		},
+	})
+	defer registry.Stop()
+
+	if _, err := registry.Get("example.com"); !errors.Is(err, fastbreaker.ErrInvalidConfiguration) {
+		t.Fatalf("expected %v but got %v.", fastbreaker.ErrInvalidConfiguration, err)
+	}
+	if registry.Len() != 0 {
+		t.Fatalf("expected an empty registry but got %d circuit breakers.", registry.Len())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected NewRegistry to panic with an invalid template.")
+		}
+	}()
+	fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{BreakJitter: 2},
+	})
+}
+
+func TestRegistryConcurrentGet(t *testing.T) {
+	const goroutines = 8
+
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: newFakeClock()},
+	})
+	defer registry.Stop()
+
+	breakers := make([]fastbreaker.FastBreaker, goroutines)
+	var wg sync.WaitGroup
+	for i := range breakers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			breakers[i], _ = registry.Get("example.com")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, cb := range breakers {
+		if cb == nil || cb != breakers[0] {
+			t.Fatal("expected every goroutine to get the same circuit breaker.")
+		}
+	}
+}
+
+func TestRegistryMaxSize(t *testing.T) {
+	var evictions []string
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: newFakeClock(), TripPolicy: fastbreaker.ConsecutiveFailures(1)},
+		MaxSize:  2,
+		OnEvict: func(key string, cb fastbreaker.FastBreaker, reason fastbreaker.EvictionReason) {
+			if cb.State() != fastbreaker.StateStopped {
+				t.Errorf("circuit breaker should be %s but it is %s.", fastbreaker.StateStopped, cb.State())
+			}
+			evictions = append(evictions, key+" "+reason.String())
+		},
+	})
+	defer registry.Stop()
+
+	// The least recently used circuit breaker should be evicted.
+	a := getAndAssert(t, registry, "a")
+	getAndAssert(t, registry, "b")
+	getAndAssert(t, registry, "a")
+	getAndAssert(t, registry, "c")
+	assertRegistry(t, registry, evictions, []string{"a", "c"}, []string{"b capacity"})
+
+	// Open circuit breakers should not be evicted.
+	allowAndAssert(t, a, true)(false)
+	getAndAssert(t, registry, "d")
+	assertRegistry(t, registry, evictions, []string{"a", "d"}, []string{"b capacity", "c capacity"})
+
+	// The registry should exceed MaxSize rather than evict an open circuit breaker.
+	d := getAndAssert(t, registry, "d")
+	allowAndAssert(t, d, true)(false)
+	getAndAssert(t, registry, "e")
+	assertRegistry(t, registry, evictions, []string{"a", "d", "e"}, []string{"b capacity", "c capacity"})
+}
+
+func TestRegistryMaxSizeWithoutGet(t *testing.T) {
+	var evictions []string
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: newFakeClock()},
+		MaxSize:  2,
+		OnEvict: func(key string, cb fastbreaker.FastBreaker, reason fastbreaker.EvictionReason) {
+			evictions = append(evictions, key+" "+reason.String())
+		},
+	})
+	defer registry.Stop()
+
+	// A circuit breaker kept by the caller should not be evicted while it executes.
+	a := getAndAssert(t, registry, "a")
+	getAndAssert(t, registry, "b")
+	allowAndAssert(t, a, true)(true)
+	getAndAssert(t, registry, "c")
+	assertRegistry(t, registry, evictions, []string{"a", "c"}, []string{"b capacity"})
+}
+
+func TestRegistryOverrideUsesRegistry(t *testing.T) {
+	var registry *fastbreaker.Registry
+	registry = fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: newFakeClock()},
+		MaxSize:  10,
+		Override: func(key string, configuration fastbreaker.Configuration) fastbreaker.Configuration {
+			// Copy the configuration of the parent key.
+			if parent := strings.TrimSuffix(key, "/child"); parent != key {
+				cb, err := registry.Get(parent)
+				if err != nil {
+					t.Errorf("unexpected error %v.", err)
+					return configuration
+				}
+				parentConfiguration := cb.Configuration()
+				parentConfiguration.Name = key
+				return parentConfiguration
+			}
+			configuration.DurationOfBreak = time.Minute
+			return configuration
+		},
+	})
+	defer registry.Stop()
+
+	cb := getAndAssert(t, registry, "a/child")
+	if cb.Configuration().DurationOfBreak != time.Minute {
+		t.Fatalf("expected the parent DurationOfBreak but got %s.", cb.Configuration().DurationOfBreak)
+	}
+	if keys := registry.Keys(); !reflect.DeepEqual(keys, []string{"a", "a/child"}) {
+		t.Fatalf("unexpected keys %v.", keys)
+	}
+}
+
+func TestRegistryEvictionRace(t *testing.T) {
+	const breakers = 100
+
+	changes := make(map[fastbreaker.FastBreaker]chan fastbreaker.StateChange)
+	var mutex sync.Mutex
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{Clock: newFakeClock(), TripPolicy: fastbreaker.ConsecutiveFailures(1)},
+		MaxSize:  1,
+		OnEvict: func(key string, cb fastbreaker.FastBreaker, reason fastbreaker.EvictionReason) {
+			mutex.Lock()
+			ch, ok := changes[cb]
+			mutex.Unlock()
+			if !ok {
+				return
+			}
+			// The evicted circuit breakers should have been stopped while closed.
+			for change := range ch {
+				if change.To == fastbreaker.StateStopped {
+					if change.From != fastbreaker.StateClosed {
+						t.Errorf("circuit breaker %s evicted from %s.", key, change.From)
+					}
+					return
+				}
+			}
+		},
+	})
+	defer registry.Stop()
+
+	// Trip every circuit breaker while the next one evicts it.
+	for i := 0; i < breakers; i++ {
+		cb := getAndAssert(t, registry, fmt.Sprint(i))
+		ch := make(chan fastbreaker.StateChange, 4)
+		cb.Notify(ch)
+		mutex.Lock()
+		changes[cb] = ch
+		mutex.Unlock()
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if permit, err := cb.Acquire(); err == nil {
+				permit.Failure()
+			}
+		}()
+		getAndAssert(t, registry, fmt.Sprint(i, "-next"))
+		wg.Wait()
+	}
+}
+
+func TestRegistryGetEvictedMeanwhile(t *testing.T) {
+	clock := &hookClock{FakeClock: newFakeClock()}
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template:    fastbreaker.Configuration{Clock: clock},
+		MaxSize:     1,
+		IdleTimeout: time.Hour,
+	})
+	defer registry.Stop()
+
+	evicted := getAndAssert(t, registry, "a")
+
+	// Evict the circuit breaker from another goroutine while Get is touching it.
+	var hooked atomic.Bool
+	clock.hook = func() {
+		if !hooked.CompareAndSwap(false, true) {
+			return
+		}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := registry.Get("b"); err != nil {
+				t.Errorf("unexpected error %v.", err)
+			}
+		}()
+		wg.Wait()
+	}
+	cb := getAndAssert(t, registry, "a")
+	if evicted.State() != fastbreaker.StateStopped {
+		t.Fatalf("expected the first circuit breaker to be evicted but it is %s.", evicted.State())
+	}
+	if cb == evicted || cb.State() != fastbreaker.StateClosed {
+		t.Fatalf("expected a new closed circuit breaker but got the evicted one in %s.", cb.State())
+	}
+
+	// The circuit breakers stopped by their callers should be replaced too.
+	cb.Stop()
+	if replaced := getAndAssert(t, registry, "a"); replaced == cb || replaced.State() != fastbreaker.StateClosed {
+		t.Fatalf("expected a new closed circuit breaker but got the stopped one in %s.", replaced.State())
+	}
+}
+
+func TestRegistryIdleTimeout(t *testing.T) {
+	clock := newFakeClock()
+	var evictions []string
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template: fastbreaker.Configuration{
+			DurationOfBreak:    10 * time.Minute,
+			MaxDurationOfBreak: 10 * time.Minute,
+			TripPolicy:         fastbreaker.ConsecutiveFailures(1),
+			Clock:              clock,
+		},
+		IdleTimeout: time.Minute,
+		OnEvict: func(key string, cb fastbreaker.FastBreaker, reason fastbreaker.EvictionReason) {
+			evictions = append(evictions, key+" "+reason.String())
+		},
+	})
+	defer registry.Stop()
+
+	// The circuit breakers not requested for IdleTimeout should be evicted.
+	getAndAssert(t, registry, "a")
+	b := getAndAssert(t, registry, "b")
+	clock.Advance(30 * time.Second)
+	a := getAndAssert(t, registry, "a")
+	allowAndAssert(t, a, true)(false)
+	clock.Advance(30 * time.Second)
+	assertRegistry(t, registry, evictions, []string{"a"}, []string{"b idle"})
+	if b.State() != fastbreaker.StateStopped {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateStopped, b.State())
+	}
+
+	// Idle circuit breakers should not be evicted while they are open or half-open.
+	clock.Advance(10 * time.Minute)
+	if a.State() != fastbreaker.StateHalfOpen {
+		t.Fatalf("circuit breaker should be %s but it is %s.", fastbreaker.StateHalfOpen, a.State())
+	}
+	assertRegistry(t, registry, evictions, []string{"a"}, []string{"b idle"})
+
+	// Idle circuit breakers should be evicted once they close.
+	allowAndAssert(t, a, true)(true)
+	clock.Advance(time.Minute)
+	assertRegistry(t, registry, evictions, []string{}, []string{"b idle", "a idle"})
+}
+
+func TestRegistryIdleTimeoutWithoutGet(t *testing.T) {
+	clock := newFakeClock()
+	var evictions []string
+	registry := fastbreaker.NewRegistry(fastbreaker.RegistryConfiguration{
+		Template:    fastbreaker.Configuration{Clock: clock},
+		IdleTimeout: time.Minute,
+		OnEvict: func(key string, cb fastbreaker.FastBreaker, reason fastbreaker.EvictionReason) {
+			evictions = append(evictions, key+" "+reason.String())
+		},
+	})
+	defer registry.Stop()
+
+	// A circuit breaker kept by the caller should not be evicted while it executes.
+	cb := getAndAssert(t, registry, "a")
+	for i := 0; i < 300; i++ {
+		permit, err := cb.Acquire()
+		if err != nil {
+			t.Fatalf("unexpected error %v after %d seconds.", err, i)
+		}
+		permit.Success()
+		clock.Advance(time.Second)
+	}
+	assertRegistry(t, registry, evictions, []string{"a"}, nil)
+
+	// It should be evicted once it stops executing.
+	clock.Advance(2 * time.Minute)
+	assertRegistry(t, registry, evictions, []string{}, []string{"a idle"})
+}
+
+func TestEvictionReasonString(t *testing.T) {
+	tests := map[fastbreaker.EvictionReason]string{
+		fastbreaker.EvictionCapacity:    "capacity",
+		fastbreaker.EvictionIdle:        "idle",
+		fastbreaker.EvictionReason(100): "unknown eviction reason 100",
+	}
+
+	for reason, expected := range tests {
+		if reason.String() != expected {
+			t.Errorf("expected %q but got %q.", expected, reason.String())
+		}
+	}
+}
+
+func assertRegistry(t *testing.T, registry *fastbreaker.Registry, evictions []string, expectedKeys []string, expectedEvictions []string) {
+	t.Helper()
+
+	if keys := registry.Keys(); !reflect.DeepEqual(keys, expectedKeys) {
+		t.Fatalf("expected keys %v but got %v.", expectedKeys, keys)
+	}
+	if !reflect.DeepEqual(evictions, expectedEvictions) {
+		t.Fatalf("expected evictions %v but got %v.", expectedEvictions, evictions)
+	}
+}
+
+func getAndAssert(t *testing.T, registry *fastbreaker.Registry, key string) fastbreaker.FastBreaker {
+	t.Helper()
+
+	cb, err := registry.Get(key)
+	if err != nil {
+		t.Fatalf("unexpected error %v.", err)
+	}
+	return cb
+}
@@ -2,6 +2,7 @@ package fastbreaker
 
 import (
 	"fmt"
+	"sync/atomic"
 )
 
 // State represents the state of a circuit breaker.
@@ -17,6 +18,12 @@ func (state State) String() string {
 		return "half-open"
 	case StateOpen:
 		return "open"
+	case StateForcedOpen:
+		return "forced-open"
+	case StateForcedClosed:
+		return "forced-closed"
+	case StateIsolated:
+		return "isolated"
 	default:
 		return fmt.Sprintf("unknown state %d", state)
 	}
@@ -32,4 +39,31 @@ const (
 	StateHalfOpen
 	// StateOpen is the circuit breaker state when it is rejecting executions.
 	StateOpen
+	// StateForcedOpen is the circuit breaker state when it has been manually opened with ForceOpen.
+	StateForcedOpen
+	// StateForcedClosed is the circuit breaker state when it has been manually closed with ForceClose.
+	StateForcedClosed
+	// StateIsolated is the circuit breaker state when it has been manually isolated with Isolate.
+	StateIsolated
 )
+
+// atomicState is a State that can be read and updated atomically.
+type atomicState struct {
+	value atomic.Uint32
+}
+
+func (s *atomicState) Load() State {
+	return State(s.value.Load())
+}
+
+func (s *atomicState) Store(state State) {
+	s.value.Store(uint32(state))
+}
+
+func (s *atomicState) Swap(state State) State {
+	return State(s.value.Swap(uint32(state)))
+}
+
+func (s *atomicState) CompareAndSwap(old State, new State) bool {
+	return s.value.CompareAndSwap(uint32(old), uint32(new))
+}
@@ -0,0 +1,66 @@
+package fastbreaker
+
+import "time"
+
+// Stats is a snapshot of the counters of a circuit breaker. It is passed to the TripPolicy every
+// time an execution fails or is slow in the closed state.
+type Stats struct {
+	// Time is the time of the snapshot, as reported by the circuit breaker Clock.
+	Time time.Time
+	// Executions is the number of executions in the rolling window.
+	Executions uint64
+	// Failures is the number of failures in the rolling window.
+	Failures uint64
+	// SlowCalls is the number of slow calls in the rolling window.
+	SlowCalls uint64
+	// Timeouts is the number of executions in the rolling window that timed out. Timeouts are
+	// counted as failures too.
+	Timeouts uint64
+	// ConsecutiveFailures is the number of executions failed in a row.
+	ConsecutiveFailures uint64
+	// ConsecutiveSuccesses is the number of executions succeeded in a row.
+	ConsecutiveSuccesses uint64
+	// Rejected is the number of executions the circuit breaker has rejected.
+	Rejected uint64
+	// WindowElapsed is the time covered by the rolling window. It is the time since the circuit
+	// closed, limited to NumBuckets * BucketDuration for time based windows.
+	WindowElapsed time.Duration
+	// LastTrip is the time the circuit last opened. It is the zero time if the circuit never opened.
+	LastTrip time.Time
+}
+
+// FailureRate returns the fraction of the executions in the rolling window that failed.
+// It returns 0 when there are no executions.
+func (stats Stats) FailureRate() float64 {
+	if stats.Executions == 0 {
+		return 0
+	}
+	return float64(stats.Failures) / float64(stats.Executions)
+}
+
+// SlowCallRate returns the fraction of the executions in the rolling window that were slow.
+// It returns 0 when there are no executions.
+func (stats Stats) SlowCallRate() float64 {
+	if stats.Executions == 0 {
+		return 0
+	}
+	return float64(stats.SlowCalls) / float64(stats.Executions)
+}
+
+// TimeoutRate returns the fraction of the executions in the rolling window that timed out.
+// It returns 0 when there are no executions.
+func (stats Stats) TimeoutRate() float64 {
+	if stats.Executions == 0 {
+		return 0
+	}
+	return float64(stats.Timeouts) / float64(stats.Executions)
+}
+
+// SinceLastTrip returns the time elapsed between the last time the circuit opened and the snapshot.
+// It returns 0 if the circuit never opened.
+func (stats Stats) SinceLastTrip() time.Duration {
+	if stats.LastTrip.IsZero() {
+		return 0
+	}
+	return stats.Time.Sub(stats.LastTrip)
+}
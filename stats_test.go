@@ -0,0 +1,67 @@
+package fastbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluekiri/fastbreaker"
+)
+
+func TestStatsRates(t *testing.T) {
+	type testSpec struct {
+		name               string
+		stats              fastbreaker.Stats
+		expectFailureRate  float64
+		expectSlowCallRate float64
+		expectTimeoutRate  float64
+	}
+
+	tests := []testSpec{
+		{"empty", fastbreaker.Stats{}, 0, 0, 0},
+		{"no failures", fastbreaker.Stats{Executions: 10}, 0, 0, 0},
+		{"half", fastbreaker.Stats{Executions: 10, Failures: 5, SlowCalls: 2, Timeouts: 1}, 0.5, 0.2, 0.1},
+		{"all", fastbreaker.Stats{Executions: 10, Failures: 10, SlowCalls: 10, Timeouts: 10}, 1, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.stats.FailureRate(); actual != tt.expectFailureRate {
+				t.Errorf("expected failure rate %g but got %g", tt.expectFailureRate, actual)
+			}
+			if actual := tt.stats.SlowCallRate(); actual != tt.expectSlowCallRate {
+				t.Errorf("expected slow call rate %g but got %g", tt.expectSlowCallRate, actual)
+			}
+			if actual := tt.stats.TimeoutRate(); actual != tt.expectTimeoutRate {
+				t.Errorf("expected timeout rate %g but got %g", tt.expectTimeoutRate, actual)
+			}
+		})
+	}
+}
+
+func TestStatsSinceLastTrip(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	if actual := (fastbreaker.Stats{Time: now}).SinceLastTrip(); actual != 0 {
+		t.Errorf("expected 0 since last trip but got %s", actual)
+	}
+
+	stats := fastbreaker.Stats{Time: now, LastTrip: now.Add(-time.Minute)}
+	if actual := stats.SinceLastTrip(); actual != time.Minute {
+		t.Errorf("expected %s since last trip but got %s", time.Minute, actual)
+	}
+}
+
+func TestShouldTripFunc(t *testing.T) {
+	var actualExecutions, actualFailures uint64
+	policy := fastbreaker.ShouldTripFunc(func(executions uint64, failures uint64) bool {
+		actualExecutions, actualFailures = executions, failures
+		return true
+	})
+
+	if !policy.ShouldTrip(fastbreaker.Stats{Executions: 20, Failures: 10, SlowCalls: 5}) {
+		t.Fatalf("expected ShouldTrip to return true")
+	}
+	if actualExecutions != 20 || actualFailures != 10 {
+		t.Fatalf("expected 20 executions and 10 failures but got %d and %d", actualExecutions, actualFailures)
+	}
+}
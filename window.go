@@ -0,0 +1,336 @@
+package fastbreaker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// cacheLineSize is the assumed size of a CPU cache line.
+const cacheLineSize = 64
+
+// counts is a snapshot of counters.
+type counts struct {
+	executions uint64
+	failures   uint64
+	slowCalls  uint64
+	timeouts   uint64
+}
+
+// add returns the sum of both counts.
+func (c counts) add(other counts) counts {
+	return counts{
+		executions: c.executions + other.executions,
+		failures:   c.failures + other.failures,
+		slowCalls:  c.slowCalls + other.slowCalls,
+		timeouts:   c.timeouts + other.timeouts,
+	}
+}
+
+type counters struct {
+	executions atomic.Uint64
+	failures   atomic.Uint64
+	slowCalls  atomic.Uint64
+	timeouts   atomic.Uint64
+}
+
+func (c *counters) reset() {
+	c.executions.Store(0)
+	c.failures.Store(0)
+	c.slowCalls.Store(0)
+	c.timeouts.Store(0)
+}
+
+// record increments the counters with the outcome of an execution. Timeouts are failures too.
+func (c *counters) record(outcome Outcome, slow bool) {
+	c.executions.Add(1)
+	if outcome != OutcomeSuccess {
+		c.failures.Add(1)
+	}
+	if outcome == OutcomeTimeout {
+		c.timeouts.Add(1)
+	}
+	if slow {
+		c.slowCalls.Add(1)
+	}
+}
+
+func (c *counters) load() counts {
+	return counts{
+		executions: c.executions.Load(),
+		failures:   c.failures.Load(),
+		slowCalls:  c.slowCalls.Load(),
+		timeouts:   c.timeouts.Load(),
+	}
+}
+
+// paddedCounters are counters padded to fill a whole cache line, so updating them does not
+// invalidate the cache line of their neighbours.
+type paddedCounters struct {
+	counters
+	_ [cacheLineSize - 4*8]byte
+}
+
+// stripedCounters spread the counters over several cache line stripes, so concurrent executions
+// do not contend on the same atomics. Every execution is recorded in the stripe chosen by the caller
+// and the stripes are summed on read.
+type stripedCounters struct {
+	stripes []paddedCounters
+}
+
+func newStripedCounters(numStripes int) stripedCounters {
+	return stripedCounters{stripes: make([]paddedCounters, numStripes)}
+}
+
+func (c *stripedCounters) reset() {
+	for i := range c.stripes {
+		c.stripes[i].reset()
+	}
+}
+
+// record increments the counters of the stripe with the outcome of an execution.
+func (c *stripedCounters) record(stripe uint32, outcome Outcome, slow bool) {
+	c.stripes[stripe%uint32(len(c.stripes))].record(outcome, slow)
+}
+
+// load returns the sum of the counters of all the stripes.
+func (c *stripedCounters) load() counts {
+	var total counts
+	for i := range c.stripes {
+		total = total.add(c.stripes[i].load())
+	}
+	return total
+}
+
+// paddedCounter is a counter padded to fill a whole cache line.
+type paddedCounter struct {
+	atomic.Uint64
+	_ [cacheLineSize - 8]byte
+}
+
+// stripedCounter is a single counter spread over several cache line stripes like stripedCounters.
+type stripedCounter struct {
+	stripes []paddedCounter
+}
+
+func newStripedCounter(numStripes int) stripedCounter {
+	return stripedCounter{stripes: make([]paddedCounter, numStripes)}
+}
+
+// add increments the counter of the stripe.
+func (c *stripedCounter) add(stripe uint32) {
+	c.stripes[stripe%uint32(len(c.stripes))].Add(1)
+}
+
+// load returns the sum of the counters of all the stripes.
+func (c *stripedCounter) load() uint64 {
+	var value uint64 = 0
+	for i := range c.stripes {
+		value += c.stripes[i].Load()
+	}
+	return value
+}
+
+// reset sets the counter of every stripe to zero. Stripes already at zero are not written.
+func (c *stripedCounter) reset() {
+	for i := range c.stripes {
+		if c.stripes[i].Load() != 0 {
+			c.stripes[i].Store(0)
+		}
+	}
+}
+
+// window holds the outcomes of the recent executions.
+type window interface {
+	// record adds the outcome of an execution to the window. stripe is a random number used to spread
+	// concurrent executions over the counter stripes.
+	record(stripe uint32, outcome Outcome, slow bool)
+
+	// counters returns the number of executions, failures, slow calls and timeouts in the window.
+	counters() counts
+
+	// reset removes every outcome from the window.
+	reset()
+}
+
+// timeWindow is a window holding the outcomes of the executions of the last NumBuckets buckets of
+// BucketDuration. The window must be advanced every BucketDuration.
+type timeWindow struct {
+	buckets []stripedCounters
+	// head is the number of times the window has been advanced. The current bucket is
+	// buckets[head % len(buckets)].
+	head atomic.Uint64
+}
+
+func newTimeWindow(numBuckets int, numStripes int) *timeWindow {
+	w := &timeWindow{buckets: make([]stripedCounters, numBuckets)}
+	for i := range w.buckets {
+		w.buckets[i] = newStripedCounters(numStripes)
+	}
+	return w
+}
+
+func (w *timeWindow) record(stripe uint32, outcome Outcome, slow bool) {
+	w.bucket(w.head.Load()).record(stripe, outcome, slow)
+}
+
+func (w *timeWindow) counters() counts {
+	var total counts
+	for i := range w.buckets {
+		total = total.add(w.buckets[i].load())
+	}
+	return total
+}
+
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i].reset()
+	}
+}
+
+// advance moves the head of the window to the next bucket. advance must not be called concurrently.
+func (w *timeWindow) advance() {
+	next := w.head.Load() + 1
+
+	// reset the next bucket before moving the head to it.
+	w.bucket(next).reset()
+	w.head.Store(next)
+}
+
+// bucket returns the bucket of the head.
+func (w *timeWindow) bucket(head uint64) *stripedCounters {
+	return &w.buckets[head%uint64(len(w.buckets))]
+}
+
+// lazyTimeWindow is a window holding the outcomes of the executions of the last NumBuckets buckets of
+// BucketDuration. Instead of being advanced periodically, the buckets are indexed by the number of
+// BucketDuration elapsed since the window was created (epoch) and stale buckets are reset on access.
+type lazyTimeWindow struct {
+	clock          Clock
+	start          time.Time
+	bucketDuration time.Duration
+	buckets        []lazyBucket
+}
+
+type lazyBucket struct {
+	stripedCounters
+	// epoch is the epoch the counters belong to.
+	epoch atomic.Int64
+}
+
+func newLazyTimeWindow(numBuckets int, numStripes int, bucketDuration time.Duration, clock Clock) *lazyTimeWindow {
+	w := &lazyTimeWindow{
+		clock:          clock,
+		start:          clock.Now(),
+		bucketDuration: bucketDuration,
+		buckets:        make([]lazyBucket, numBuckets),
+	}
+	for i := range w.buckets {
+		w.buckets[i].stripedCounters = newStripedCounters(numStripes)
+	}
+	return w
+}
+
+func (w *lazyTimeWindow) record(stripe uint32, outcome Outcome, slow bool) {
+	epoch := w.epoch()
+	bucket := &w.buckets[epoch%int64(len(w.buckets))]
+
+	// Reset the bucket if it belongs to a previous epoch. Outcomes recorded concurrently while the
+	// bucket is being reset may be lost.
+	for {
+		bucketEpoch := bucket.epoch.Load()
+		if bucketEpoch >= epoch {
+			break
+		}
+		if bucket.epoch.CompareAndSwap(bucketEpoch, epoch) {
+			bucket.reset()
+			break
+		}
+	}
+
+	bucket.record(stripe, outcome, slow)
+}
+
+func (w *lazyTimeWindow) counters() counts {
+	epoch := w.epoch()
+	var total counts
+	for i := range w.buckets {
+		// Skip the buckets of the epochs out of the window.
+		if epoch-w.buckets[i].epoch.Load() >= int64(len(w.buckets)) {
+			continue
+		}
+		total = total.add(w.buckets[i].load())
+	}
+	return total
+}
+
+func (w *lazyTimeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i].reset()
+	}
+}
+
+// epoch returns the number of BucketDuration elapsed since the window was created.
+func (w *lazyTimeWindow) epoch() int64 {
+	return int64(w.clock.Now().Sub(w.start) / w.bucketDuration)
+}
+
+// Outcome flags stored in every slot of a countWindow.
+const (
+	outcomeRecorded uint32 = 1 << iota
+	outcomeFailed
+	outcomeSlow
+	outcomeTimedOut
+)
+
+// countWindow is a window holding the outcomes of the last WindowSize executions.
+type countWindow struct {
+	outcomes []atomic.Uint32
+	position atomic.Uint64
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{outcomes: make([]atomic.Uint32, size)}
+}
+
+func (w *countWindow) record(_ uint32, outcome Outcome, slow bool) {
+	flags := outcomeRecorded
+	if outcome != OutcomeSuccess {
+		flags |= outcomeFailed
+	}
+	if outcome == OutcomeTimeout {
+		flags |= outcomeTimedOut
+	}
+	if slow {
+		flags |= outcomeSlow
+	}
+
+	// Overwrite the oldest outcome.
+	position := w.position.Add(1) - 1
+	w.outcomes[position%uint64(len(w.outcomes))].Store(flags)
+}
+
+func (w *countWindow) counters() counts {
+	var total counts
+	for i := range w.outcomes {
+		flags := w.outcomes[i].Load()
+		if flags&outcomeRecorded != 0 {
+			total.executions++
+		}
+		if flags&outcomeFailed != 0 {
+			total.failures++
+		}
+		if flags&outcomeSlow != 0 {
+			total.slowCalls++
+		}
+		if flags&outcomeTimedOut != 0 {
+			total.timeouts++
+		}
+	}
+	return total
+}
+
+func (w *countWindow) reset() {
+	for i := range w.outcomes {
+		w.outcomes[i].Store(0)
+	}
+}